@@ -0,0 +1,137 @@
+package batch_query
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Fill ratio of the batch below which batch is considered as poorly filled.
+	adaptiveLowFill = .5
+	// Count of additive steps between min and max bounds.
+	adaptiveSteps = 16
+)
+
+// AdaptiveConfig describes bounds of adaptive batch size and collect interval.
+// Effective values adjust using AIMD approach:
+//   - batch size and collect interval halve if batch latency exceeds target latency;
+//   - if batches flush by size, batch size increases and collect interval decreases additively;
+//   - if batches flush by interval poorly filled, batch size halves, or collect interval decreases additively if batch
+//     size has already reached the minimum, thus low load doesn't wait for long collect intervals;
+//   - if batches flush by interval well filled, collect interval increases additively to let batches fill completely.
+type AdaptiveConfig struct {
+	// Bounds of batch size.
+	// If omit, MinBatchSize is 1 and MaxBatchSize is Config.BatchSize.
+	MinBatchSize uint64
+	MaxBatchSize uint64
+	// Bounds of collect interval.
+	// If omit, MinCollectInterval is 1/10 of Config.CollectInterval and MaxCollectInterval is Config.CollectInterval.
+	MinCollectInterval time.Duration
+	MaxCollectInterval time.Duration
+	// Desired latency of batch processing.
+	// Zero value disables decreasing by latency.
+	TargetLatency time.Duration
+}
+
+func (c *AdaptiveConfig) Copy() *AdaptiveConfig {
+	cpy := *c
+	return &cpy
+}
+
+// Internal AIMD controller of batch size and collect interval.
+type adaptive struct {
+	mux  sync.Mutex
+	conf *AdaptiveConfig
+}
+
+func newAdaptive(conf *AdaptiveConfig) *adaptive {
+	a := adaptive{conf: conf}
+	return &a
+}
+
+// Replace bounds of the controller.
+func (a *adaptive) setConf(conf *AdaptiveConfig) {
+	a.mux.Lock()
+	a.conf = conf
+	a.mux.Unlock()
+}
+
+// Clamp batch size and collect interval to the nearest bounds.
+func (c *AdaptiveConfig) clamp(size uint64, ival time.Duration) (uint64, time.Duration) {
+	if size < c.MinBatchSize {
+		size = c.MinBatchSize
+	}
+	if size > c.MaxBatchSize {
+		size = c.MaxBatchSize
+	}
+	if ival < c.MinCollectInterval {
+		ival = c.MinCollectInterval
+	}
+	if ival > c.MaxCollectInterval {
+		ival = c.MaxCollectInterval
+	}
+	return size, ival
+}
+
+// Adjust effective batch size and collect interval according outcome of the batch.
+func (a *adaptive) adjust(q *BatchQuery, b *batch, dur time.Duration) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	c := a.conf
+	size, ival := q.batchSize(), q.collectInterval()
+	nsize, nival := size, ival
+	istep := time.Duration(stepOf(uint64(c.MaxCollectInterval - c.MinCollectInterval)))
+	switch {
+	case c.TargetLatency > 0 && dur > c.TargetLatency:
+		nsize, nival = size/2, ival/2
+	case b.reason == flushReasonSize:
+		nsize = size + stepOf(c.MaxBatchSize-c.MinBatchSize)
+		nival = ival - istep
+	case b.reason == flushReasonInterval && float64(len(b.pairs))/float64(size) < adaptiveLowFill:
+		if size > c.MinBatchSize {
+			nsize = size / 2
+		} else {
+			nival = ival - istep
+		}
+	case b.reason == flushReasonInterval:
+		nival = ival + istep
+	}
+	nsize, nival = c.clamp(nsize, nival)
+	if nsize == size && nival == ival {
+		return
+	}
+	atomic.StoreUint64(&q.bsize, nsize)
+	atomic.StoreInt64(&q.cival, int64(nival))
+	if l := q.l(); l != nil {
+		l.Printf("adaptive: batch size %d, collect interval %s\n", nsize, nival)
+	}
+	q.mw().Adapt(nsize, nival)
+}
+
+func stepOf(span uint64) uint64 {
+	if step := span / adaptiveSteps; step > 0 {
+		return step
+	}
+	return 1
+}
+
+// BatchSize returns current effective batch size.
+func (q *BatchQuery) BatchSize() uint64 {
+	q.once.Do(q.init)
+	return q.batchSize()
+}
+
+// CollectInterval returns current effective collect interval.
+func (q *BatchQuery) CollectInterval() time.Duration {
+	q.once.Do(q.init)
+	return q.collectInterval()
+}
+
+func (q *BatchQuery) batchSize() uint64 {
+	return atomic.LoadUint64(&q.bsize)
+}
+
+func (q *BatchQuery) collectInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&q.cival))
+}
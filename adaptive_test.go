@@ -0,0 +1,61 @@
+package batch_query
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveClamp(t *testing.T) {
+	c := AdaptiveConfig{MinBatchSize: 16, MaxBatchSize: 64, MinCollectInterval: time.Millisecond,
+		MaxCollectInterval: 10 * time.Millisecond}
+	stages := []struct {
+		size, wsize uint64
+		ival, wival time.Duration
+	}{
+		{size: 32, wsize: 32, ival: 5 * time.Millisecond, wival: 5 * time.Millisecond},
+		{size: 8, wsize: 16, ival: time.Microsecond, wival: time.Millisecond},
+		{size: 128, wsize: 64, ival: time.Second, wival: 10 * time.Millisecond},
+	}
+	for i, stg := range stages {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if size, ival := c.clamp(stg.size, stg.ival); size != stg.wsize || ival != stg.wival {
+				t.Fatalf("unexpected batch size %d and collect interval %s", size, ival)
+			}
+		})
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	t.Run("low load", func(t *testing.T) {
+		// Poorly filled batches shrink batch size and then collect interval.
+		q := newTestQuery(t, &Config{BatchSize: 64, CollectInterval: 10 * time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &testBatcher{}, Adaptive: &AdaptiveConfig{MinBatchSize: 4, MaxBatchSize: 64}})
+		for i := 1; i <= 40; i++ {
+			if _, err := q.Fetch(i); err != nil && err != ErrNotFound {
+				t.Fatal(err)
+			}
+		}
+		if size, ival := q.BatchSize(), q.CollectInterval(); size != 4 || ival != time.Millisecond {
+			t.Fatalf("unexpected batch size %d and collect interval %s", size, ival)
+		}
+	})
+	t.Run("high load", func(t *testing.T) {
+		// Batches filled by size increase batch size.
+		q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: 10 * time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &testBatcher{}, Adaptive: &AdaptiveConfig{MinBatchSize: 4, MaxBatchSize: 64}})
+		q.FetchMany([]any{1, 2, 3, 4})
+		if size, ival := q.BatchSize(), q.CollectInterval(); size <= 4 || ival >= 10*time.Millisecond {
+			t.Fatalf("unexpected batch size %d and collect interval %s", size, ival)
+		}
+	})
+	t.Run("latency", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 64, CollectInterval: 10 * time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &testBatcher{delay: 5 * time.Millisecond},
+			Adaptive: &AdaptiveConfig{MinBatchSize: 4, MaxBatchSize: 64, TargetLatency: time.Millisecond}})
+		q.Fetch(1)
+		if size, ival := q.BatchSize(), q.CollectInterval(); size != 32 || ival != 5*time.Millisecond {
+			t.Fatalf("unexpected batch size %d and collect interval %s", size, ival)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package batch_query
 import (
 	"context"
 	"math"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,13 +29,44 @@ type BatchQuery struct {
 	once   sync.Once
 	config *Config
 	status Status
-
-	mux    sync.Mutex
-	buf    []pair
-	c      chan []pair
+	// Current config, may be replaced by Reconfigure.
+	live atomic.Value
+	rmux sync.Mutex
+
+	// Collecting buffers, each one has own lock and timer.
+	shards []*shard
+	rr     uint64
+	c      chan *batch
 	idx    uint64
-	timer  *timer
+	wctx   context.Context
 	cancel context.CancelFunc
+	// Counters of running and busy workers.
+	workers uint32
+	busy    uint32
+	// Duration of the last processed batch.
+	blast int64
+	// Stop signals of excess workers and count of workers that should stop.
+	// Signal without pending retirement is ignored, so retirement may be cancelled by decrement of the counter.
+	quit     chan struct{}
+	retiring uint32
+	wg       sync.WaitGroup
+
+	cache    *cache
+	ncache   *cache
+	breaker  *breaker
+	adaptive *adaptive
+	// Effective batch size and collect interval.
+	bsize uint64
+	cival int64
+
+	// In-flight keys, uses in single flight mode.
+	fmux   sync.Mutex
+	flight map[any]*flight
+
+	stats stats
+	// Status change notification channel, see Wait.
+	smux sync.Mutex
+	sc   chan struct{}
 
 	err error
 }
@@ -45,11 +77,14 @@ func New(conf *Config) (*BatchQuery, error) {
 		return nil, ErrNoConfig
 	}
 	q := BatchQuery{config: conf.Copy()}
-	q.once.Do(q.init)
-	return &q, q.err
+	if q.once.Do(q.init); q.err != nil {
+		return nil, q.err
+	}
+	return &q, nil
 }
 
 func (q *BatchQuery) init() {
+	q.stats.q = q
 	if q.config == nil {
 		q.err = ErrNoConfig
 		q.status = StatusFail
@@ -61,113 +96,43 @@ func (q *BatchQuery) init() {
 	c := q.config
 
 	// Check config params.
-	if c.BatchSize == 0 {
-		c.BatchSize = defaultBatchSize
-	}
-	if c.CollectInterval <= 0 {
-		c.CollectInterval = defaultCollectInterval
-	}
-	if c.TimeoutInterval <= 0 {
-		c.TimeoutInterval = defaultTimeoutInterval
-	}
-	if c.TimeoutInterval < c.CollectInterval {
-		q.err = ErrBadIntervals
+	if q.err = c.prepare(); q.err != nil {
 		q.status = StatusFail
 		return
 	}
-	q.timer = newTimer()
-	go q.timer.observe(q)
+	q.live.Store(c)
 
-	if c.Workers == 0 {
-		q.err = ErrNoWorkers
-		q.status = StatusFail
-		return
+	q.bsize, q.cival = c.BatchSize, int64(c.CollectInterval)
+	if c.Adaptive != nil {
+		q.adaptive = newAdaptive(c.Adaptive)
+		bsize, cival := c.Adaptive.clamp(c.BatchSize, c.CollectInterval)
+		q.bsize, q.cival = bsize, int64(cival)
 	}
-	if c.Buffer == 0 {
-		c.Buffer = defaultBuffer
+	if c.Cache != nil {
+		q.cache = newCache(c.Cache)
 	}
-	if c.Batcher == nil {
-		q.err = ErrNoBatcher
-		q.status = StatusFail
-		return
+	if c.NegativeCache != nil {
+		q.ncache = newCache(c.NegativeCache)
 	}
-
-	if c.MetricsWriter == nil {
-		c.MetricsWriter = DummyMetrics{}
+	if c.Breaker != nil {
+		q.breaker = newBreaker(c.Breaker)
 	}
 
-	q.c = make(chan []pair, q.config.Buffer)
+	q.c = make(chan *batch, c.Buffer)
+	q.quit = make(chan struct{})
 	q.idx = math.MaxUint64
 
+	q.shards = make([]*shard, c.Shards)
+	for i := range q.shards {
+		s := newShard(c.Clock)
+		q.shards[i] = s
+		go s.timer.observe(q, s)
+	}
+
 	// Run internal workers.
-	var ctx context.Context
-	ctx, q.cancel = context.WithCancel(context.Background())
+	q.wctx, q.cancel = context.WithCancel(context.Background())
 	for i := uint(0); i < c.Workers; i++ {
-		go func(ctx context.Context) {
-			for {
-				select {
-				case p, ok := <-q.c:
-					if !ok {
-						return
-					}
-					q.mw().BufferOut()
-					idx := atomic.AddUint64(&q.idx, 1)
-					// Prepare keys.
-					keys := make([]any, 0, len(p))
-					for i := 0; i < len(p); i++ {
-						keys = append(keys, p[i].key)
-					}
-					if l := q.l(); l != nil {
-						l.Printf("batch #%d of %d keys\n", idx, len(keys))
-					}
-					// Exec batch operation.
-					dst := make([]any, 0, len(p))
-					var err error
-					now := q.now()
-					dst, err = q.config.Batcher.Batch(dst, keys, ctx)
-					if err != nil {
-						if l := q.l(); l != nil {
-							l.Printf("batch #%d failed due to error: %s\n", idx, err.Error())
-						}
-						q.mw().BatchFail()
-						// Report about error encountered.
-						for i := 0; i < len(p); i++ {
-							p[i].c <- tuple{err: err}
-						}
-						continue
-					}
-					q.mw().BatchOK(q.now().Sub(now))
-					var s, r int
-					// Send values to corresponding channels.
-					for i := 0; i < len(dst); i++ {
-						for j := 0; j < len(p); j++ {
-							if p[j].done {
-								continue
-							}
-							if p[j].done = q.config.Batcher.MatchKey(p[j].key, dst[i]); p[j].done {
-								p[j].c <- tuple{val: dst[i]}
-								close(p[j].c)
-								s++
-								continue
-							}
-						}
-					}
-					// Check rest of keys.
-					for i := 0; i < len(p); i++ {
-						if !p[i].done {
-							p[i].c <- tuple{err: ErrNotFound}
-							close(p[i].c)
-							r++
-						}
-					}
-					if l := q.l(); l != nil {
-						l.Printf("batch #%d finish with %d success jobs, %d jobs unresponded\n", idx, s, r)
-					}
-				case <-ctx.Done():
-					return
-				}
-			}
-		}(ctx)
+		q.spawn()
 	}
 
 	q.setStatus(StatusActive)
@@ -175,17 +140,12 @@ func (q *BatchQuery) init() {
 
 // Fetch add single request to current batch using default timeout interval.
 func (q *BatchQuery) Fetch(key any) (any, error) {
-	return q.FetchTimeout(key, q.config.TimeoutInterval)
+	return q.FetchTimeout(key, q.cfg().TimeoutInterval)
 }
 
 // FetchContext add single request to current batch with context.
 func (q *BatchQuery) FetchContext(key any, ctx context.Context) (any, error) {
-	q.once.Do(q.init)
-	if status := q.getStatus(); status == StatusClose || status == StatusFail {
-		return nil, ErrQueryClosed
-	}
-
-	return q.fetch(key, ctx, ctxInt)
+	return q.fetch(key, ctx)
 }
 
 // FetchTimeout add single request to current batch using given timeout interval.
@@ -193,137 +153,512 @@ func (q *BatchQuery) FetchTimeout(key any, timeout time.Duration) (any, error) {
 	if timeout <= 0 {
 		return nil, ErrTimeout
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	_ = cancel
-	return q.fetch(key, ctx, ctxTO)
+	q.once.Do(q.init)
+	if err := q.admit(1); err != nil {
+		return nil, err
+	}
+	return q.fetchAsync(key, q.deadline(q.now(), timeout)).Wait()
 }
 
 // FetchDeadline add single request to current batch using given deadline.
 func (q *BatchQuery) FetchDeadline(key any, deadline time.Time) (any, error) {
-	timeout := -time.Since(deadline)
+	q.once.Do(q.init)
+	timeout := deadline.Sub(q.now())
 	return q.FetchTimeout(key, timeout)
 }
 
-func (q *BatchQuery) fetch(key any, ctx context.Context, ctxt uint8) (any, error) {
+func (q *BatchQuery) fetch(key any, ctx context.Context) (any, error) {
+	q.once.Do(q.init)
+	if err := q.admit(1); err != nil {
+		return nil, err
+	}
+
+	f := q.fetchAsync(key, q.ctxDeadline(ctx))
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return f.abandon(ctxInt)
+	}
+}
+
+// FetchAsync adds single request to current batch and returns future without waiting the response.
+// Future's Wait method uses default timeout interval.
+func (q *BatchQuery) FetchAsync(key any) *Future {
+	q.once.Do(q.init)
+	if err := q.admit(1); err != nil {
+		return failedFuture(err)
+	}
+	return q.fetchAsync(key, q.deadline(q.now(), q.cfg().TimeoutInterval))
+}
+
+func (q *BatchQuery) fetchAsync(key any, deadline time.Time) *Future {
 	q.once.Do(q.init)
 
 	q.mw().Fetch()
-	c := make(chan tuple, 1)
+	f := newFuture(q, q.now(), deadline)
+	id := q.ident(key)
+	if val, err, ok := q.cached(id); ok {
+		f.resolve(val, err)
+		return f
+	}
+	s := q.shardOf(id)
+	s.mux.Lock()
+	q.pushLF(s, pair{key: key, id: id, dl: deadline, f: f})
+	s.mux.Unlock()
+	return f
+}
+
+// FetchMany adds multiple requests to current batch using default timeout interval.
+// Returns values and errors in the same order as keys.
+func (q *BatchQuery) FetchMany(keys []any) ([]any, []error) {
+	return q.FetchManyTimeout(keys, q.cfg().TimeoutInterval)
+}
+
+// FetchManyContext adds multiple requests to current batch with context.
+func (q *BatchQuery) FetchManyContext(keys []any, ctx context.Context) ([]any, []error) {
+	return q.fetchMany(keys, ctx, time.Time{})
+}
+
+// FetchManyTimeout adds multiple requests to current batch using given timeout interval.
+func (q *BatchQuery) FetchManyTimeout(keys []any, timeout time.Duration) ([]any, []error) {
+	if timeout <= 0 {
+		return make([]any, len(keys)), fillErr(make([]error, len(keys)), ErrTimeout)
+	}
+	q.once.Do(q.init)
+	return q.fetchMany(keys, context.Background(), q.deadline(q.now(), timeout))
+}
+
+// FetchManyDeadline adds multiple requests to current batch using given deadline.
+func (q *BatchQuery) FetchManyDeadline(keys []any, deadline time.Time) ([]any, []error) {
+	q.once.Do(q.init)
+	timeout := deadline.Sub(q.now())
+	return q.FetchManyTimeout(keys, timeout)
+}
+
+// Fetch multiple keys until context done or deadline reach.
+// Zero deadline means that deadline takes from the context.
+func (q *BatchQuery) fetchMany(keys []any, ctx context.Context, deadline time.Time) ([]any, []error) {
+	q.once.Do(q.init)
+	vals, errs := make([]any, len(keys)), make([]error, len(keys))
+	if len(keys) == 0 {
+		return vals, errs
+	}
+	if err := q.admit(len(keys)); err != nil {
+		return vals, fillErr(errs, err)
+	}
+
+	// All keys share the same response channel, tuple's index points to the position of the key.
+	c := make(chan tuple, len(keys))
 	now := q.now()
-	q.fetch1(key, c)
-	select {
-	case rec := <-c:
-		switch {
-		case rec.err != nil && rec.err == ErrNotFound:
-			q.mw().NotFound()
-		case rec.err != nil && rec.err != ErrNotFound:
-			q.mw().Fail()
-		default:
-			q.mw().OK(q.now().Sub(now))
+	dl := deadline
+	var tc <-chan time.Time
+	if dl.IsZero() {
+		dl = q.ctxDeadline(ctx)
+	} else {
+		t := q.clock().NewTimer(dl.Sub(now))
+		defer t.Stop()
+		tc = t.C()
+	}
+	done := make([]bool, len(keys))
+	ids := make([]any, len(keys))
+	var wait int
+	for i := 0; i < len(keys); i++ {
+		q.mw().Fetch()
+		ids[i] = q.ident(keys[i])
+		if val, err, ok := q.cached(ids[i]); ok {
+			q.observe(tuple{val: val, err: err}, now)
+			vals[i], errs[i], done[i] = val, err, true
+			continue
 		}
-		return rec.val, rec.err
-	case <-ctx.Done():
-		switch ctxt {
-		case ctxTO:
-			q.mw().Timeout()
-			return nil, ErrTimeout
-		case ctxInt:
-			fallthrough
-		default:
-			q.mw().Interrupt()
-			return nil, ErrInterrupt
+		wait++
+	}
+	var g group
+	if wait > 0 {
+		q.pushMany(keys, ids, done, dl, c, &g)
+	}
+
+	for n := 0; n < wait; n++ {
+		select {
+		case rec := <-c:
+			q.observe(rec, now)
+			vals[rec.i], errs[rec.i] = rec.val, rec.err
+			done[rec.i] = true
+		case <-ctx.Done():
+			// Mark the rest of keys as interrupted.
+			g.leave()
+			return vals, q.abortRest(errs, done, ctxInt)
+		case <-tc:
+			// Mark the rest of keys as timed out.
+			g.leave()
+			return vals, q.abortRest(errs, done, ctxTO)
+		}
+	}
+	return vals, errs
+}
+
+// Register response of single request in metrics.
+func (q *BatchQuery) observe(rec tuple, start time.Time) {
+	switch {
+	case rec.err != nil && rec.err == ErrNotFound:
+		q.mw().NotFound()
+	case rec.err != nil && rec.err != ErrNotFound:
+		q.mw().Fail()
+	default:
+		q.mw().OK(q.now().Sub(start))
+	}
+}
+
+// Register single request that hasn't response due to context done and return corresponding error.
+func (q *BatchQuery) abort(ctxt uint8) error {
+	switch ctxt {
+	case ctxTO:
+		q.mw().Timeout()
+		return ErrTimeout
+	case ctxInt:
+		fallthrough
+	default:
+		q.mw().Interrupt()
+		return ErrInterrupt
+	}
+}
+
+// Register keys that haven't response and fill their errors.
+func (q *BatchQuery) abortRest(errs []error, done []bool, ctxt uint8) []error {
+	for i := 0; i < len(errs); i++ {
+		if !done[i] {
+			errs[i] = q.abort(ctxt)
+		}
+	}
+	return errs
+}
+
+// Add pair to current batch of the shard. Must be called under the shard lock.
+func (q *BatchQuery) pushLF(s *shard, p pair) {
+	if s.closed {
+		// Query has been closed concurrently.
+		p.reply(nil, ErrQueryClosed)
+		return
+	}
+	if q.cfg().SingleFlight && q.attach(p) {
+		return
+	}
+	s.buf = append(s.buf, p)
+	if uint64(len(s.buf)) >= q.batchSize() {
+		// Cancelled requests shouldn't take place in the batch.
+		q.pruneLF(s)
+	}
+	if uint64(len(s.buf)) >= q.batchSize() {
+		s.timer.pause()
+		q.flushLF(s, flushReasonSize)
+		return
+	}
+	if s.timer.paused() {
+		s.timer.resume()
+	}
+	if margin := q.cfg().DeadlineMargin; margin > 0 && !p.dl.IsZero() {
+		// Flush early if waiting of collect interval end would break the deadline.
+		at := p.dl.Add(-margin)
+		if !at.After(q.now()) {
+			s.timer.pause()
+			q.flushLF(s, flushReasonDeadline)
+			return
 		}
+		s.timer.deadline(at)
 	}
 }
 
-func (q *BatchQuery) fetch1(key any, c chan tuple) {
-	q.mux.Lock()
-	defer q.mux.Unlock()
-	q.buf = append(q.buf, pair{key: key, c: c})
-	if uint64(len(q.buf)) == q.config.BatchSize {
-		q.timer.pause()
-		q.flushLF(flushReasonSize)
+// Add pairs of not done keys to current batches. Each shard locks once.
+func (q *BatchQuery) pushMany(keys, ids []any, done []bool, dl time.Time, c chan tuple, g *group) {
+	if len(q.shards) == 1 {
+		s := q.shards[0]
+		s.mux.Lock()
+		for i := 0; i < len(keys); i++ {
+			if !done[i] {
+				q.pushLF(s, pair{key: keys[i], id: ids[i], dl: dl, c: c, i: i, g: g})
+			}
+		}
+		s.mux.Unlock()
 		return
 	}
-	if q.timer.paused() {
-		go q.timer.resume()
+	ss := make([]*shard, len(keys))
+	for i := 0; i < len(keys); i++ {
+		if !done[i] {
+			ss[i] = q.shardOf(ids[i])
+		}
+	}
+	for i := 0; i < len(keys); i++ {
+		s := ss[i]
+		if s == nil {
+			continue
+		}
+		s.mux.Lock()
+		for j := i; j < len(keys); j++ {
+			if ss[j] == s {
+				q.pushLF(s, pair{key: keys[j], id: ids[j], dl: dl, c: c, i: j, g: g})
+				ss[j] = nil
+			}
+		}
+		s.mux.Unlock()
 	}
 }
 
 // Close gracefully stops the query.
+// Collected requests flush to the workers, that process remaining batches and exit in background. See Shutdown to
+// wait for it.
 func (q *BatchQuery) Close() error {
-	if q.getStatus() == StatusNil {
-		return ErrQueryNil
+	if err := q.shut(); err != nil {
+		return err
 	}
-	if q.getStatus() == StatusClose {
-		return ErrQueryClosed
-	}
-	q.setStatus(StatusClose)
-	q.timer.stop()
-	q.mux.Lock()
-	defer q.mux.Unlock()
-	q.flushLF(flushReasonForce)
-	close(q.c)
-	q.cancel()
+	q.drain(false)
+	go func() {
+		q.wg.Wait()
+		q.cancel()
+	}()
 	if l := q.l(); l != nil {
 		l.Printf("caught close signal\n")
 	}
 	return nil
 }
 
-// ForceClose closes the query and immediately flush all batches.
+// ForceClose closes the query and immediately interrupts all collected and processing requests with ErrInterrupt.
 func (q *BatchQuery) ForceClose() error {
-	if q.getStatus() == StatusNil {
-		return ErrQueryNil
+	if err := q.shut(); err != nil {
+		return err
 	}
-	if q.getStatus() == StatusClose {
-		return ErrQueryClosed
+	// Interrupt batches concurrently to release requesters blocked on full buffer.
+	ic := make(chan int, 1)
+	go func() { ic <- q.interrupt() }()
+	c := q.drain(true)
+	c += <-ic
+	if l := q.l(); l != nil {
+		l.Printf("caught force close signal, %d jobs interrupted\n", c)
 	}
-	q.setStatus(StatusClose)
-	q.timer.stop()
-	q.mux.Lock()
-	defer q.mux.Unlock()
-	close(q.c)
-	var c int
-	for x := range q.c {
-		for _, p := range x {
-			p.c <- tuple{err: ErrInterrupt}
-			c++
+	return nil
+}
+
+// Shutdown gracefully stops the query and waits until all workers exit.
+// New requests are rejected with ErrQueryClosed, collected requests flush to the workers, that process remaining
+// batches. If ctx is done before, the rest of requests are interrupted with ErrInterrupt, processing batches are
+// cancelled and ctx error returns. Anyway, each pending request gets either the result or an error.
+func (q *BatchQuery) Shutdown(ctx context.Context) error {
+	if err := q.shut(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		q.drain(false)
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.cancel()
+		if l := q.l(); l != nil {
+			l.Printf("shutdown complete\n")
+		}
+		return nil
+	case <-ctx.Done():
+		c := q.interrupt()
+		<-done
+		if l := q.l(); l != nil {
+			l.Printf("shutdown interrupted, %d jobs interrupted\n", c)
+		}
+		return ctx.Err()
+	}
+}
+
+// Switch the query to closed status, thus new requests will be rejected.
+// Takes reconfiguration lock, so concurrent Reconfigure can't spawn workers after close.
+func (q *BatchQuery) shut() error {
+	q.rmux.Lock()
+	defer q.rmux.Unlock()
+	for {
+		s := q.getStatus()
+		if s == StatusNil {
+			return ErrQueryNil
+		}
+		if s == StatusFail || s == StatusClose {
+			return ErrQueryClosed
+		}
+		if q.casStatus(s, StatusClose) {
+			return nil
+		}
+	}
+}
+
+// Stop collecting and close the buffer.
+// If force is false collected requests flush to the buffer, otherwise they are interrupted. Returns count of
+// interrupted requests.
+func (q *BatchQuery) drain(force bool) (c int) {
+	q.lockShards()
+	defer q.unlockShards()
+	for _, s := range q.shards {
+		if force {
+			for i := 0; i < len(s.buf); i++ {
+				s.buf[i].reply(nil, ErrInterrupt)
+				c++
+			}
+			s.buf = s.buf[:0]
+		} else {
+			q.flushLF(s, flushReasonForce)
 		}
+		s.closed = true
 	}
+	close(q.c)
+	return
+}
+
+// Interrupt requests of batches remaining in the buffer and cancel processing batches.
+// Returns after the buffer close with count of interrupted requests.
+func (q *BatchQuery) interrupt() (c int) {
 	q.cancel()
-	if l := q.l(); l != nil {
-		l.Printf("caught force close signal, %d jobs interrupted\n", c)
+	for b := range q.c {
+		q.mw().BufferOut()
+		for i := 0; i < len(b.pairs); i++ {
+			b.pairs[i].reply(nil, ErrInterrupt)
+			c++
+		}
+		q.land(b, nil, ErrInterrupt)
+		b.w.cancel()
 	}
-	return nil
+	return
 }
 
 func (q *BatchQuery) Error() error {
 	return q.err
 }
 
+// Check if query may accept count of new requests.
+func (q *BatchQuery) admit(count int) error {
+	switch q.getStatus() {
+	case StatusClose, StatusFail:
+		return ErrQueryClosed
+	case StatusThrottle:
+		if q.breaker != nil && !q.breaker.allow(q, count) {
+			for i := 0; i < count; i++ {
+				q.mw().Throttle()
+			}
+			return ErrThrottled
+		}
+	}
+	return nil
+}
+
 func (q *BatchQuery) setStatus(status Status) {
 	atomic.StoreUint32((*uint32)(&q.status), uint32(status))
+	q.notify()
+}
+
+func (q *BatchQuery) casStatus(old, new Status) bool {
+	if !atomic.CompareAndSwapUint32((*uint32)(&q.status), uint32(old), uint32(new)) {
+		return false
+	}
+	q.notify()
+	return true
 }
 
 func (q *BatchQuery) getStatus() Status {
 	return Status(atomic.LoadUint32((*uint32)(&q.status)))
 }
 
-func (q *BatchQuery) mw() MetricsWriter {
-	return q.config.MetricsWriter
+// Get current config.
+func (q *BatchQuery) cfg() *Config {
+	if c, ok := q.live.Load().(*Config); ok {
+		return c
+	}
+	return q.config
+}
+
+// Get metrics writer, that counts stats and passes metrics to configured writer.
+func (q *BatchQuery) mw() MetricsWriterExt {
+	return &q.stats
+}
+
+// Get comparable identity of the key to use it as map key.
+// Returns nil if key has no identity, and thus it can't be deduplicated.
+func (q *BatchQuery) ident(key any) any {
+	id := key
+	if kb, ok := q.cfg().Batcher.(KeyedBatcher); ok {
+		id = kb.KeyIdent(key)
+	} else if p, ok := key.([]byte); ok {
+		return string(p)
+	}
+	if !hashable(id) {
+		return nil
+	}
+	return id
+}
+
+// Cache of hashable checks of identity types.
+var hashTypes sync.Map
+
+// Check if identity may be safely used as map key.
+// Comparable types with interface fields aren't hashable, since their dynamic values may be unhashable (eg. slices).
+func hashable(id any) bool {
+	switch id.(type) {
+	case nil:
+		return false
+	case string, int, int32, int64, uint, uint32, uint64:
+		return true
+	}
+	t := reflect.TypeOf(id)
+	if ok, found := hashTypes.Load(t); found {
+		return ok.(bool)
+	}
+	ok := hashableType(t)
+	hashTypes.Store(t, ok)
+	return ok
+}
+
+func hashableType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return false
+	case reflect.Array:
+		return hashableType(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !hashableType(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return t.Comparable()
+	}
+}
+
+// Calculate deadline of the request using timeout.
+// Returns zero time (no deadline) if timeout is too big to represent the deadline.
+func (q *BatchQuery) deadline(now time.Time, timeout time.Duration) time.Time {
+	dl := now.Add(timeout)
+	if dl.Before(now) {
+		return time.Time{}
+	}
+	return dl
 }
 
 func (q *BatchQuery) l() Logger {
-	return q.config.Logger
+	return q.cfg().Logger
 }
 
-func (q *BatchQuery) now() (t time.Time) {
-	// Maybe use clock?
-	t = time.Now()
-	return
+func (q *BatchQuery) now() time.Time {
+	return q.clock().Now()
+}
+
+func (q *BatchQuery) clock() Clock {
+	if c := q.cfg(); c != nil && c.Clock != nil {
+		return c.Clock
+	}
+	return SystemClock{}
+}
+
+func fillErr(dst []error, err error) []error {
+	for i := 0; i < len(dst); i++ {
+		dst[i] = err
+	}
+	return dst
 }
 
 var _ = New
-var _ = StatusThrottle
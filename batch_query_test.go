@@ -0,0 +1,128 @@
+package batch_query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFetchMany(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 2, Batcher: &testBatcher{}})
+		keys := []any{7, 10, 1, 3, 20, 9, 1}
+		vals, errs := q.FetchMany(keys)
+		for i := 0; i < len(keys); i++ {
+			k := keys[i].(int)
+			if k%10 == 0 {
+				if vals[i] != nil || errs[i] != ErrNotFound {
+					t.Fatalf("key %d: unexpected result %v %v", k, vals[i], errs[i])
+				}
+				continue
+			}
+			if vals[i] != k*2 || errs[i] != nil {
+				t.Fatalf("key %d: unexpected result %v %v", k, vals[i], errs[i])
+			}
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		q := newTestQuery(t, &Config{Workers: 1, Batcher: &testBatcher{}})
+		if vals, errs := q.FetchMany(nil); len(vals) != 0 || len(errs) != 0 {
+			t.FailNow()
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 2, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &testBatcher{delay: time.Second}})
+		_, errs := q.FetchManyTimeout([]any{1, 2, 3}, 10*time.Millisecond)
+		for i := 0; i < len(errs); i++ {
+			if errs[i] != ErrTimeout {
+				t.Fatalf("unexpected error %v", errs[i])
+			}
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 2, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &testBatcher{delay: time.Second}})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, errs := q.FetchManyContext([]any{1, 2, 3}, ctx)
+		for i := 0; i < len(errs); i++ {
+			if errs[i] != ErrInterrupt {
+				t.Fatalf("unexpected error %v", errs[i])
+			}
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	conf := Config{BatchSize: 100, CollectInterval: time.Second, TimeoutInterval: 2 * time.Second, Workers: 1}
+	t.Run("close", func(t *testing.T) {
+		c := conf
+		c.Batcher = &testBatcher{}
+		q := newTestQuery(t, &c)
+		f := q.FetchAsync(1)
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Collected requests flush on close.
+		if v, err := f.Wait(); v != 2 || err != nil {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if _, err := q.Fetch(2); err != ErrQueryClosed {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if err := q.Close(); err != ErrQueryClosed {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("force", func(t *testing.T) {
+		c := conf
+		c.BatchSize = 1
+		c.Batcher = &testBatcher{delay: 10 * time.Second}
+		q := newTestQuery(t, &c)
+		fs := []*Future{q.FetchAsync(1), q.FetchAsync(2)}
+		waitFor(t, func() bool { return len(c.Batcher.(*testBatcher).calls()) > 0 })
+		if err := q.ForceClose(); err != nil {
+			t.Fatal(err)
+		}
+		// Both processing and queued requests are interrupted.
+		for _, f := range fs {
+			if _, err := f.Wait(); err != ErrInterrupt {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		c := conf
+		c.Batcher = &testBatcher{delay: 50 * time.Millisecond}
+		q := newTestQuery(t, &c)
+		f := q.FetchAsync(1)
+		if err := q.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if !ready(f)() {
+			t.Fatal("shutdown returned before requests processed")
+		}
+		if v, err := f.Result(); v != 2 || err != nil {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if n := q.Stats().Workers; n != 0 {
+			t.Fatalf("%d workers running after shutdown", n)
+		}
+	})
+	t.Run("shutdown timeout", func(t *testing.T) {
+		c := conf
+		c.Batcher = &testBatcher{delay: 10 * time.Second}
+		q := newTestQuery(t, &c)
+		f := q.FetchAsync(1)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := q.Shutdown(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := f.Wait(); err != ErrInterrupt {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
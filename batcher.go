@@ -9,3 +9,46 @@ type Batcher interface {
 	// MatchKey checks if key corresponds to val.
 	MatchKey(key, val any) bool
 }
+
+// Pinger describes batcher that can check availability of the storage.
+// See BatchQuery.Healthy.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// KeyedBatcher describes batcher that can provide comparable identities of keys and values.
+// Allows to match values with keys using map instead of calling MatchKey for each pair of key and value.
+type KeyedBatcher interface {
+	Batcher
+	// KeyIdent returns comparable identity of the key.
+	KeyIdent(key any) any
+	// ValueIdent returns comparable identity of the key that val corresponds to.
+	// Nil identity means that value doesn't correspond to any key.
+	ValueIdent(val any) any
+}
+
+// OrderedBatcher describes batcher that returns values aligned index-for-index with keys.
+// Query skips matching of keys and values for such batchers.
+type OrderedBatcher interface {
+	Batcher
+	// BatchOrdered processes collected batch.
+	// Result must contain exactly one value per key in the same order as keys. Missing entries must be nil.
+	BatchOrdered(dst []any, keys []any, ctx context.Context) ([]any, error)
+}
+
+// Result represents response to single key of the batch.
+type Result struct {
+	// Value of the key. Nil value means that key wasn't found.
+	Value any
+	// Error of the key processing.
+	Err error
+}
+
+// ResultBatcher describes batcher that may report value or error for each key separately.
+// Thus, failure of one key doesn't fail the whole batch.
+type ResultBatcher interface {
+	Batcher
+	// BatchResults processes collected batch.
+	// Result must contain exactly one item per key in the same order as keys. Returned error fails the whole batch.
+	BatchResults(dst []Result, keys []any, ctx context.Context) ([]Result, error)
+}
@@ -0,0 +1,118 @@
+package batch_query
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Keyed batcher, counts calls of KeyIdent.
+type keyedTestBatcher struct {
+	testBatcher
+	idents int32
+}
+
+func (b *keyedTestBatcher) KeyIdent(key any) any {
+	atomic.AddInt32(&b.idents, 1)
+	return key
+}
+
+func (b *keyedTestBatcher) ValueIdent(val any) any {
+	return val.(int) / 2
+}
+
+// Ordered batcher, returns nil for missing keys. Drops the last value if misalign is set.
+type orderedTestBatcher struct {
+	testBatcher
+	misalign bool
+}
+
+func (b *orderedTestBatcher) BatchOrdered(dst []any, keys []any, _ context.Context) ([]any, error) {
+	for i := 0; i < len(keys); i++ {
+		if k := keys[i].(int); k%10 != 0 {
+			dst = append(dst, k*2)
+		} else {
+			dst = append(dst, nil)
+		}
+	}
+	if b.misalign {
+		dst = dst[:len(dst)-1]
+	}
+	return dst, nil
+}
+
+// Result batcher, fails odd keys.
+type resultTestBatcher struct {
+	testBatcher
+}
+
+func (b *resultTestBatcher) BatchResults(dst []Result, keys []any, _ context.Context) ([]Result, error) {
+	for i := 0; i < len(keys); i++ {
+		if k := keys[i].(int); k%2 != 0 {
+			dst = append(dst, Result{Err: errTest})
+		} else {
+			dst = append(dst, Result{Value: k * 2})
+		}
+	}
+	return dst, nil
+}
+
+func TestKeyedBatcher(t *testing.T) {
+	b := &keyedTestBatcher{}
+	q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+		Workers: 1, Batcher: b})
+	keys := []any{1, 2, 3, 10}
+	vals, errs := q.FetchMany(keys)
+	for i := 0; i < 3; i++ {
+		if vals[i] != keys[i].(int)*2 || errs[i] != nil {
+			t.Fatalf("unexpected result %v %v", vals[i], errs[i])
+		}
+	}
+	if errs[3] != ErrNotFound {
+		t.Fatalf("unexpected error %v", errs[3])
+	}
+	// Identities calculate once per key on fetch and reuse on matching.
+	if n := atomic.LoadInt32(&b.idents); n != int32(len(keys)) {
+		t.Fatalf("KeyIdent called %d times", n)
+	}
+}
+
+func TestOrderedBatcher(t *testing.T) {
+	t.Run("aligned", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 3, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &orderedTestBatcher{}})
+		vals, errs := q.FetchMany([]any{10, 5, 7})
+		if errs[0] != ErrNotFound || vals[1] != 10 || vals[2] != 14 {
+			t.Fatalf("unexpected result %v %v", vals, errs)
+		}
+		if b := q.cfg().Batcher.(*orderedTestBatcher); len(b.calls()) != 0 {
+			t.Fatal("unordered method called")
+		}
+	})
+	t.Run("misaligned", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 3, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &orderedTestBatcher{misalign: true}})
+		_, errs := q.FetchMany([]any{1, 2, 3})
+		for i := 0; i < len(errs); i++ {
+			if errs[i] != ErrMisaligned {
+				t.Fatalf("unexpected error %v", errs[i])
+			}
+		}
+	})
+}
+
+func TestResultBatcher(t *testing.T) {
+	q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+		Workers: 1, Batcher: &resultTestBatcher{}})
+	vals, errs := q.FetchMany([]any{1, 2, 3, 4})
+	for i := 0; i < len(vals); i++ {
+		k := i + 1
+		if k%2 != 0 && errs[i] != errTest {
+			t.Fatalf("key %d: unexpected error %v", k, errs[i])
+		}
+		if k%2 == 0 && (vals[i] != k*2 || errs[i] != nil) {
+			t.Fatalf("key %d: unexpected result %v %v", k, vals[i], errs[i])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package batch_query
+
+import (
+	"context"
+	"time"
+)
+
+// Split failed batch into halves recursively and exec them until poison keys will be isolated.
+// Only isolated keys get the error, results of the rest keys return as usual.
+func (q *BatchQuery) bisect(keys, ids []any, deadline time.Time, idx uint64, ctx context.Context) []Result {
+	res := make([]Result, len(keys))
+	q.bisectTo(res, keys, ids, deadline, idx, ctx)
+	return res
+}
+
+func (q *BatchQuery) bisectTo(dst []Result, keys, ids []any, deadline time.Time, idx uint64, ctx context.Context) {
+	mid := len(keys) / 2
+	for _, part := range [2][2]int{{0, mid}, {mid, len(keys)}} {
+		lo, hi := part[0], part[1]
+		res, err := q.batchRetry(keys[lo:hi], ids[lo:hi], deadline, idx, ctx)
+		switch {
+		case err == nil:
+			copy(dst[lo:hi], res)
+		case hi-lo == 1:
+			dst[lo].Err = err
+			if l := q.l(); l != nil {
+				l.Printf("batch #%d: key %v isolated due to error: %s\n", idx, keys[lo], err.Error())
+			}
+			q.mw().Isolate()
+		case ctx.Err() != nil:
+			// No reason to continue bisecting.
+			for i := lo; i < hi; i++ {
+				dst[i].Err = err
+			}
+		default:
+			q.bisectTo(dst[lo:hi], keys[lo:hi], ids[lo:hi], deadline, idx, ctx)
+		}
+	}
+}
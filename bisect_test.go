@@ -0,0 +1,42 @@
+package batch_query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBisect(t *testing.T) {
+	// Batches containing the poison key fail.
+	b := &testBatcher{fail: func(keys []any) error {
+		for i := 0; i < len(keys); i++ {
+			if keys[i] == 3 {
+				return errTest
+			}
+		}
+		return nil
+	}}
+	q := newTestQuery(t, &Config{BatchSize: 8, CollectInterval: time.Second, TimeoutInterval: time.Second,
+		Workers: 1, Batcher: b, Bisect: true})
+	keys := []any{1, 2, 3, 4, 5, 6, 7, 8}
+	vals, errs := q.FetchMany(keys)
+	for i := 0; i < len(keys); i++ {
+		k := keys[i].(int)
+		if k == 3 {
+			if errs[i] != errTest {
+				t.Fatalf("unexpected error of poison key %v", errs[i])
+			}
+			continue
+		}
+		if vals[i] != k*2 || errs[i] != nil {
+			t.Fatalf("key %d: unexpected result %v %v", k, vals[i], errs[i])
+		}
+	}
+	st := q.Stats()
+	if st.Isolate != 1 {
+		t.Fatalf("unexpected isolated count %d", st.Isolate)
+	}
+	// Batch of 8 keys takes 1 + 2*log2(8) requests.
+	if n := len(b.calls()); n != 7 {
+		t.Fatalf("%d batches processed", n)
+	}
+}
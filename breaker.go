@@ -0,0 +1,191 @@
+package batch_query
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerWindow       = 100
+	defaultBreakerOpenInterval = time.Second
+	defaultBreakerProbes       = 1
+)
+
+type breakerState uint8
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig describes circuit breaker properties.
+type BreakerConfig struct {
+	// How many last batches uses to calculate failure rate and latency.
+	// If this param omit defaultBreakerWindow (100) will use instead.
+	Window uint
+	// Failure rate of batches in range (0..1] that opens the breaker.
+	// Zero value disables the check.
+	FailureRate float64
+	// Average latency of batches that opens the breaker.
+	// Zero value disables the check.
+	Latency time.Duration
+	// How long breaker stays open before probing.
+	// If this param omit defaultBreakerOpenInterval (1 second) will use instead.
+	OpenInterval time.Duration
+	// How many successful probe batches closes the breaker.
+	// If this param omit defaultBreakerProbes (1) will use instead.
+	Probes uint
+}
+
+func (c *BreakerConfig) Copy() *BreakerConfig {
+	cpy := *c
+	return &cpy
+}
+
+func (c *BreakerConfig) equal(x *BreakerConfig) bool {
+	if c == nil || x == nil {
+		return c == x
+	}
+	return *c == *x
+}
+
+// Internal circuit breaker implementation.
+// Open and half-open states of the breaker correspond to StatusThrottle of the query.
+type breaker struct {
+	mux   sync.Mutex
+	conf  *BreakerConfig
+	state breakerState
+	// Ring of last batches outcomes.
+	ring []breakerRec
+	pos  int
+	size int
+	// Time of the last opening or probing round start.
+	opened time.Time
+	// Count of admitted requests and successful batches in half-open state.
+	admitted uint64
+	probes   uint
+}
+
+// breakerRec represents outcome of single batch.
+type breakerRec struct {
+	fail bool
+	dur  time.Duration
+}
+
+func newBreaker(conf *BreakerConfig) *breaker {
+	b := breaker{
+		conf: conf,
+		ring: make([]breakerRec, conf.Window),
+	}
+	return &b
+}
+
+// Check if count of requests may pass through open breaker.
+// Open breaker switches to half-open state after open interval and admits requests enough to make probe batches.
+func (b *breaker) allow(q *BatchQuery, count int) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		if q.now().Sub(b.opened) < b.conf.OpenInterval {
+			return false
+		}
+		b.switchLF(q, breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.admitted >= q.batchSize()*uint64(b.conf.Probes) {
+			// Probe requests may be answered without batching (eg. from cache), so start new probing round after
+			// open interval.
+			if q.now().Sub(b.opened) < b.conf.OpenInterval {
+				return false
+			}
+			b.opened, b.admitted = q.now(), 0
+		}
+		b.admitted += uint64(count)
+		return true
+	}
+	return false
+}
+
+// Register outcome of the batch and switch state if needed.
+func (b *breaker) record(q *BatchQuery, fail bool, dur time.Duration) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case breakerClosed:
+		b.ring[b.pos] = breakerRec{fail: fail, dur: dur}
+		b.pos = (b.pos + 1) % len(b.ring)
+		if b.size < len(b.ring) {
+			b.size++
+		}
+		if b.size == len(b.ring) && b.exceedLF() {
+			b.switchLF(q, breakerOpen)
+		}
+	case breakerHalfOpen:
+		if fail || (b.conf.Latency > 0 && dur >= b.conf.Latency) {
+			b.switchLF(q, breakerOpen)
+			return
+		}
+		if b.probes++; b.probes >= b.conf.Probes {
+			b.switchLF(q, breakerClosed)
+		}
+	}
+}
+
+// Check if failure rate or latency exceeds the thresholds.
+func (b *breaker) exceedLF() bool {
+	var (
+		fails, ok int
+		sum       time.Duration
+	)
+	for i := 0; i < b.size; i++ {
+		if b.ring[i].fail {
+			fails++
+			continue
+		}
+		ok++
+		sum += b.ring[i].dur
+	}
+	if b.conf.FailureRate > 0 && float64(fails)/float64(b.size) >= b.conf.FailureRate {
+		return true
+	}
+	if b.conf.Latency > 0 && ok > 0 && sum/time.Duration(ok) >= b.conf.Latency {
+		return true
+	}
+	return false
+}
+
+func (b *breaker) switchLF(q *BatchQuery, state breakerState) {
+	b.state = state
+	switch state {
+	case breakerOpen:
+		b.opened = q.now()
+		q.casStatus(StatusActive, StatusThrottle)
+	case breakerHalfOpen:
+		b.opened = q.now()
+		b.admitted, b.probes = 0, 0
+	case breakerClosed:
+		b.pos, b.size = 0, 0
+		q.casStatus(StatusThrottle, StatusActive)
+	}
+	if l := q.l(); l != nil {
+		l.Printf("circuit breaker switched to %s state\n", state.String())
+	}
+	q.mw().BreakerState(state.String())
+}
@@ -0,0 +1,60 @@
+package batch_query
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var fail int32
+	b := &testBatcher{fail: func([]any) error {
+		if atomic.LoadInt32(&fail) != 0 {
+			return errTest
+		}
+		return nil
+	}}
+	clk := NewFakeClock(time.Unix(1000, 0))
+	q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Second, Workers: 1, Batcher: b, Clock: clk,
+		Breaker: &BreakerConfig{Window: 2, FailureRate: .5, OpenInterval: time.Second}})
+	open := func() {
+		atomic.StoreInt32(&fail, 1)
+		for i := 1; i <= 2; i++ {
+			if _, err := q.Fetch(i); err != errTest {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+		if q.Status() != StatusThrottle {
+			t.Fatal("breaker didn't open")
+		}
+	}
+
+	open()
+	n := len(b.calls())
+	if _, err := q.Fetch(3); err != ErrThrottled {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(b.calls()) != n || q.Stats().Throttle != 1 {
+		t.Fatal("throttled request reached the batcher")
+	}
+
+	// Failed probe opens the breaker again.
+	clk.Advance(time.Second)
+	if _, err := q.Fetch(4); err != errTest {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := q.Fetch(5); err != ErrThrottled {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Successful probe closes the breaker.
+	atomic.StoreInt32(&fail, 0)
+	clk.Advance(time.Second)
+	if val, err := q.Fetch(6); err != nil || val != 12 {
+		t.Fatalf("unexpected result %v %v", val, err)
+	}
+	if q.Status() != StatusActive {
+		t.Fatal("breaker didn't close")
+	}
+	open()
+}
@@ -0,0 +1,201 @@
+package batch_query
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// CacheConfig describes result cache properties.
+type CacheConfig struct {
+	// Max entries count in the cache.
+	// If this param omit defaultCacheEntries (1024) will use instead.
+	MaxEntries uint64
+	// Max total size of entries in the cache, calculated using Size func.
+	// Zero value means no limit.
+	MaxSize uint64
+	// How long the entry lives in the cache.
+	// Zero value means that entries lives until eviction.
+	TTL time.Duration
+	// Size func calculates size of the entry. Values of negative cache entries are always nil.
+	// If this param omit, each entry has size 1.
+	Size func(key, val any) uint64
+}
+
+func (c *CacheConfig) Copy() *CacheConfig {
+	cpy := *c
+	return &cpy
+}
+
+func (c *CacheConfig) equal(x *CacheConfig) bool {
+	if c == nil || x == nil {
+		return c == x
+	}
+	return c.MaxEntries == x.MaxEntries && c.MaxSize == x.MaxSize && c.TTL == x.TTL &&
+		reflect.ValueOf(c.Size).Pointer() == reflect.ValueOf(x.Size).Pointer()
+}
+
+// Internal LRU cache implementation.
+type cache struct {
+	mux  sync.Mutex
+	conf *CacheConfig
+	ll   *list.List
+	idx  map[any]*list.Element
+	size uint64
+}
+
+// cacheEntry represents single value in the cache.
+type cacheEntry struct {
+	id   any
+	val  any
+	exp  time.Time
+	size uint64
+}
+
+func newCache(conf *CacheConfig) *cache {
+	c := cache{
+		conf: conf,
+		ll:   list.New(),
+		idx:  make(map[any]*list.Element),
+	}
+	return &c
+}
+
+// Get value by key identity.
+// Expired entry removes from the cache and counts as evicted.
+func (c *cache) get(id any, now time.Time) (val any, ok bool, evict int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	el, ok := c.idx[id]
+	if !ok {
+		return
+	}
+	e := el.Value.(*cacheEntry)
+	if !e.exp.IsZero() && now.After(e.exp) {
+		c.removeLF(el)
+		return nil, false, 1
+	}
+	c.ll.MoveToFront(el)
+	return e.val, true, 0
+}
+
+// Set value of key identity. Returns count of evicted entries.
+func (c *cache) set(id, key, val any, now time.Time) (evict int) {
+	var size uint64 = 1
+	if c.conf.Size != nil {
+		size = c.conf.Size(key, val)
+	}
+	if c.conf.MaxSize > 0 && size > c.conf.MaxSize {
+		// Entry will never fit into the cache.
+		return
+	}
+	var exp time.Time
+	if c.conf.TTL > 0 {
+		exp = now.Add(c.conf.TTL)
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if el, ok := c.idx[id]; ok {
+		e := el.Value.(*cacheEntry)
+		c.size = c.size - e.size + size
+		e.val, e.exp, e.size = val, exp, size
+		c.ll.MoveToFront(el)
+	} else {
+		c.idx[id] = c.ll.PushFront(&cacheEntry{id: id, val: val, exp: exp, size: size})
+		c.size += size
+	}
+	for uint64(c.ll.Len()) > c.conf.MaxEntries || (c.conf.MaxSize > 0 && c.size > c.conf.MaxSize) {
+		c.removeLF(c.ll.Back())
+		evict++
+	}
+	return
+}
+
+// Delete entry by key identity.
+func (c *cache) del(id any) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if el, ok := c.idx[id]; ok {
+		c.removeLF(el)
+	}
+}
+
+func (c *cache) removeLF(el *list.Element) {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.idx, e.id)
+	c.size -= e.size
+}
+
+// Check caches for value of the key identity.
+// Hit of negative cache returns ErrNotFound.
+func (q *BatchQuery) cached(id any) (val any, err error, ok bool) {
+	if (q.cache == nil && q.ncache == nil) || id == nil {
+		return
+	}
+	var evict, e int
+	now := q.now()
+	if q.cache != nil {
+		val, ok, evict = q.cache.get(id, now)
+	}
+	if !ok && q.ncache != nil {
+		if _, ok, e = q.ncache.get(id, now); ok {
+			err = ErrNotFound
+		}
+		evict += e
+	}
+	if evict > 0 {
+		q.mw().CacheEvict(evict)
+	}
+	if !ok {
+		q.mw().CacheMiss()
+		return
+	}
+	q.mw().CacheHit()
+	return
+}
+
+// Put found values and not found keys of the batch to the corresponding caches.
+func (q *BatchQuery) cacheResults(b *batch, res []Result) {
+	if q.cache == nil && q.ncache == nil {
+		return
+	}
+	now := q.now()
+	var evict int
+	for i := 0; i < len(res); i++ {
+		if b.ids[i] == nil || res[i].Err != nil {
+			continue
+		}
+		switch {
+		case res[i].Value != nil && q.cache != nil:
+			evict += q.cache.set(b.ids[i], b.keys[i], res[i].Value, now)
+		case res[i].Value == nil && q.ncache != nil:
+			evict += q.ncache.set(b.ids[i], b.keys[i], nil, now)
+		}
+	}
+	if evict > 0 {
+		q.mw().CacheEvict(evict)
+	}
+}
+
+// Invalidate removes given keys from result and negative caches.
+// Useful when record was created or changed in the storage.
+func (q *BatchQuery) Invalidate(keys ...any) {
+	q.once.Do(q.init)
+	if q.cache == nil && q.ncache == nil {
+		return
+	}
+	for i := 0; i < len(keys); i++ {
+		id := q.ident(keys[i])
+		if id == nil {
+			continue
+		}
+		if q.cache != nil {
+			q.cache.del(id)
+		}
+		if q.ncache != nil {
+			q.ncache.del(id)
+		}
+	}
+}
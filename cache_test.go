@@ -0,0 +1,96 @@
+package batch_query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheLRU(t *testing.T) {
+	now := time.Unix(1000, 0)
+	t.Run("entries", func(t *testing.T) {
+		c := newCache(&CacheConfig{MaxEntries: 2})
+		c.set(1, 1, "a", now)
+		c.set(2, 2, "b", now)
+		// Touch the first entry, so the second one becomes the oldest.
+		if _, ok, _ := c.get(1, now); !ok {
+			t.FailNow()
+		}
+		if evict := c.set(3, 3, "c", now); evict != 1 {
+			t.Fatalf("unexpected evict count %d", evict)
+		}
+		if _, ok, _ := c.get(2, now); ok {
+			t.Fatal("the oldest entry wasn't evicted")
+		}
+		if _, ok, _ := c.get(1, now); !ok {
+			t.Fatal("recent entry was evicted")
+		}
+	})
+	t.Run("size", func(t *testing.T) {
+		c := newCache(&CacheConfig{MaxEntries: 10, MaxSize: 5, Size: func(_, val any) uint64 {
+			return uint64(len(val.(string)))
+		}})
+		c.set(1, 1, "aaa", now)
+		c.set(2, 2, "bb", now)
+		c.set(3, 3, "cc", now)
+		if _, ok, _ := c.get(1, now); ok {
+			t.Fatal("entry wasn't evicted by size")
+		}
+		if c.set(4, 4, "xxxxxx", now); c.ll.Len() != 2 {
+			t.Fatal("entry greater than max size was cached")
+		}
+	})
+	t.Run("ttl", func(t *testing.T) {
+		c := newCache(&CacheConfig{MaxEntries: 10, TTL: time.Minute})
+		c.set(1, 1, "a", now)
+		if _, ok, _ := c.get(1, now.Add(time.Second)); !ok {
+			t.FailNow()
+		}
+		if _, ok, evict := c.get(1, now.Add(2*time.Minute)); ok || evict != 1 {
+			t.Fatal("expired entry wasn't evicted")
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	clk := NewFakeClock(time.Unix(1000, 0))
+	b := &testBatcher{}
+	q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Second, Workers: 1, Batcher: b, Clock: clk,
+		Cache: &CacheConfig{TTL: time.Minute}})
+	for i := 0; i < 3; i++ {
+		if val, err := q.Fetch(1); err != nil || val != 2 {
+			t.Fatalf("unexpected result %v %v", val, err)
+		}
+	}
+	if n := len(b.calls()); n != 1 {
+		t.Fatalf("%d batches processed", n)
+	}
+	if st := q.Stats(); st.CacheHit != 2 {
+		t.Fatalf("unexpected cache hits %d", st.CacheHit)
+	}
+	clk.Advance(2 * time.Minute)
+	q.Fetch(1)
+	if n := len(b.calls()); n != 2 {
+		t.Fatal("expired entry was used")
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	b := &testBatcher{}
+	q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Second, Workers: 1, Batcher: b,
+		Cache: &CacheConfig{}, NegativeCache: &CacheConfig{}})
+	for i := 0; i < 2; i++ {
+		if _, err := q.Fetch(10); err != ErrNotFound {
+			t.Fatalf("unexpected error %v", err)
+		}
+		q.Fetch(1)
+	}
+	if n := len(b.calls()); n != 2 {
+		t.Fatalf("%d batches processed", n)
+	}
+	q.Invalidate(1, 10)
+	q.Fetch(10)
+	q.Fetch(1)
+	if n := len(b.calls()); n != 4 {
+		t.Fatal("invalidated entries were used")
+	}
+}
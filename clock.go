@@ -0,0 +1,300 @@
+package batch_query
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a source of current time, timers and tickers.
+// Query uses it to measure latency, to calculate deadlines and to wait collect, timeout, idle and retry intervals.
+// By default, system clock uses. See FakeClock for tests.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+	NewTicker(d time.Duration) ClockTicker
+}
+
+// ClockTimer is an abstraction of time.Timer.
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// ClockTicker is an abstraction of time.Ticker.
+type ClockTicker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// SystemClock is a Clock implementation over time package.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) NewTimer(d time.Duration) ClockTimer {
+	return sysTimer{time.NewTimer(d)}
+}
+
+func (SystemClock) NewTicker(d time.Duration) ClockTicker {
+	return sysTicker{time.NewTicker(d)}
+}
+
+type sysTimer struct {
+	t *time.Timer
+}
+
+func (t sysTimer) C() <-chan time.Time        { return t.t.C }
+func (t sysTimer) Stop() bool                 { return t.t.Stop() }
+func (t sysTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type sysTicker struct {
+	t *time.Ticker
+}
+
+func (t sysTicker) C() <-chan time.Time   { return t.t.C }
+func (t sysTicker) Stop()                 { t.t.Stop() }
+func (t sysTicker) Reset(d time.Duration) { t.t.Reset(d) }
+
+// FakeClock is a manual Clock implementation for tests.
+// Time doesn't go by itself, but changes by Advance and Set calls, which fire expired timers and tickers. As system
+// timers, fake timers and tickers have buffered channel of size 1 and drop signals if channel is full.
+type FakeClock struct {
+	mux sync.Mutex
+	now time.Time
+	ws  []*fakeWaiter
+}
+
+// Fake timer or ticker.
+type fakeWaiter struct {
+	clk *FakeClock
+	c   chan time.Time
+	at  time.Time
+	// Period of ticker, zero for timers.
+	period time.Duration
+}
+
+// NewFakeClock makes new fake clock with given initial time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := FakeClock{now: now}
+	return &c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) ClockTimer {
+	w := &fakeWaiter{clk: c, c: make(chan time.Time, 1)}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.startLF(w, d)
+	return fakeTimer{w}
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) ClockTicker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	w := &fakeWaiter{clk: c, c: make(chan time.Time, 1), period: d}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.startLF(w, d)
+	return fakeTicker{w}
+}
+
+// Advance moves the clock forward by duration d and fires expired timers and tickers.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.setLF(c.now.Add(d))
+}
+
+// Set moves the clock to time t and fires expired timers and tickers.
+func (c *FakeClock) Set(t time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.setLF(t)
+}
+
+// Waiters returns count of active timers and tickers.
+// Useful to make sure that tested code has started waiting before advance the clock.
+func (c *FakeClock) Waiters() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.ws)
+}
+
+func (c *FakeClock) setLF(t time.Time) {
+	c.now = t
+	// Fire in order of expiration.
+	sort.SliceStable(c.ws, func(i, j int) bool { return c.ws[i].at.Before(c.ws[j].at) })
+	ws := c.ws[:0]
+	for _, w := range c.ws {
+		if w.at.After(t) {
+			ws = append(ws, w)
+			continue
+		}
+		w.fire(w.at)
+		if w.period > 0 {
+			// Skip missed ticks as system ticker does.
+			for !w.at.After(t) {
+				w.at = w.at.Add(w.period)
+			}
+			ws = append(ws, w)
+		}
+	}
+	c.ws = ws
+}
+
+// Schedule waiter to fire after d. Expired waiter fires immediately.
+func (c *FakeClock) startLF(w *fakeWaiter, d time.Duration) {
+	w.at = c.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		w.fire(c.now)
+		return
+	}
+	c.ws = append(c.ws, w)
+}
+
+// Remove waiter from the schedule. Returns true if waiter was active.
+func (c *FakeClock) stopLF(w *fakeWaiter) bool {
+	for i, x := range c.ws {
+		if x == w {
+			c.ws = append(c.ws[:i], c.ws[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fakeWaiter) fire(t time.Time) {
+	select {
+	case w.c <- t:
+	default:
+	}
+}
+
+type fakeTimer struct {
+	w *fakeWaiter
+}
+
+func (t fakeTimer) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t fakeTimer) Stop() bool {
+	t.w.clk.mux.Lock()
+	defer t.w.clk.mux.Unlock()
+	return t.w.clk.stopLF(t.w)
+}
+
+func (t fakeTimer) Reset(d time.Duration) bool {
+	t.w.clk.mux.Lock()
+	defer t.w.clk.mux.Unlock()
+	ok := t.w.clk.stopLF(t.w)
+	t.w.clk.startLF(t.w, d)
+	return ok
+}
+
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t fakeTicker) Stop() {
+	t.w.clk.mux.Lock()
+	defer t.w.clk.mux.Unlock()
+	t.w.clk.stopLF(t.w)
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock ticker")
+	}
+	t.w.clk.mux.Lock()
+	defer t.w.clk.mux.Unlock()
+	t.w.clk.stopLF(t.w)
+	t.w.period = d
+	t.w.clk.startLF(t.w, d)
+}
+
+// Context that is done when the query clock reaches the deadline.
+// Uses with non-system clocks, since their time has nothing common with real time of context deadlines.
+type clockCtx struct {
+	context.Context
+	mux sync.Mutex
+	err error
+}
+
+func (c *clockCtx) Err() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.Context.Err()
+}
+
+// Make context that is done when the query clock reaches the deadline.
+func (q *BatchQuery) withDeadline(parent context.Context, dl time.Time) (context.Context, context.CancelFunc) {
+	clk := q.clock()
+	if _, ok := clk.(SystemClock); ok {
+		return context.WithDeadline(parent, dl)
+	}
+	ctx, cancel := context.WithCancel(parent)
+	c := &clockCtx{Context: ctx}
+	t := clk.NewTimer(dl.Sub(clk.Now()))
+	go func() {
+		defer t.Stop()
+		select {
+		case <-t.C():
+			c.mux.Lock()
+			if ctx.Err() == nil {
+				c.err = context.DeadlineExceeded
+			}
+			cancel()
+			c.mux.Unlock()
+		case <-ctx.Done():
+		}
+	}()
+	return c, cancel
+}
+
+// Make context that is done when the timeout of the query clock expires.
+func (q *BatchQuery) withTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if dl := q.deadline(q.now(), timeout); !dl.IsZero() {
+		return q.withDeadline(parent, dl)
+	}
+	return context.WithCancel(parent)
+}
+
+// Get deadline of the context in terms of the query clock.
+// Returns zero time if context has no deadline.
+func (q *BatchQuery) ctxDeadline(ctx context.Context) time.Time {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return time.Time{}
+	}
+	if _, ok = q.clock().(SystemClock); ok {
+		return dl
+	}
+	// Context deadline is in real time, so move the remaining time to the query clock.
+	rem := time.Until(dl)
+	if rem < 0 {
+		rem = 0
+	}
+	return q.deadline(q.now(), rem)
+}
+
+var _, _ Clock = SystemClock{}, (*FakeClock)(nil)
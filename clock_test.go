@@ -0,0 +1,202 @@
+package batch_query
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	t.Run("timer", func(t *testing.T) {
+		clk := NewFakeClock(start)
+		tm := clk.NewTimer(10 * time.Second)
+		if clk.Waiters() != 1 {
+			t.FailNow()
+		}
+		clk.Advance(5 * time.Second)
+		if fired(tm.C()) {
+			t.Fatal("timer fired too early")
+		}
+		clk.Advance(5 * time.Second)
+		select {
+		case at := <-tm.C():
+			if !at.Equal(start.Add(10 * time.Second)) {
+				t.Fatalf("unexpected fire time %s", at)
+			}
+		default:
+			t.Fatal("timer didn't fire")
+		}
+		if clk.Waiters() != 0 || tm.Stop() {
+			t.Fatal("fired timer must be inactive")
+		}
+	})
+	t.Run("stop", func(t *testing.T) {
+		clk := NewFakeClock(start)
+		tm := clk.NewTimer(time.Second)
+		if !tm.Stop() {
+			t.FailNow()
+		}
+		clk.Advance(time.Minute)
+		if fired(tm.C()) {
+			t.Fatal("stopped timer fired")
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		clk := NewFakeClock(start)
+		tm := clk.NewTimer(time.Second)
+		tm.Reset(time.Minute)
+		clk.Advance(time.Second)
+		if fired(tm.C()) {
+			t.Fatal("timer fired by old duration")
+		}
+		clk.Advance(time.Minute)
+		if !fired(tm.C()) {
+			t.Fatal("timer didn't fire")
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		clk := NewFakeClock(start)
+		if tm := clk.NewTimer(0); !fired(tm.C()) {
+			t.Fatal("expired timer must fire immediately")
+		}
+	})
+	t.Run("ticker", func(t *testing.T) {
+		clk := NewFakeClock(start)
+		tk := clk.NewTicker(time.Second)
+		defer tk.Stop()
+		for i := 0; i < 3; i++ {
+			clk.Advance(time.Second)
+			if !fired(tk.C()) {
+				t.Fatalf("tick #%d missed", i)
+			}
+		}
+		// Missed ticks are dropped.
+		clk.Advance(10 * time.Second)
+		if !fired(tk.C()) || fired(tk.C()) {
+			t.Fatal("expected exactly one tick")
+		}
+		tk.Stop()
+		clk.Advance(time.Second)
+		if fired(tk.C()) || clk.Waiters() != 0 {
+			t.Fatal("stopped ticker ticked")
+		}
+	})
+	t.Run("set", func(t *testing.T) {
+		clk := NewFakeClock(start)
+		tm := clk.NewTimer(time.Hour)
+		clk.Set(start.Add(2 * time.Hour))
+		if !fired(tm.C()) || !clk.Now().Equal(start.Add(2*time.Hour)) {
+			t.FailNow()
+		}
+	})
+}
+
+func TestFakeClockQuery(t *testing.T) {
+	t.Run("collect interval", func(t *testing.T) {
+		clk := NewFakeClock(time.Unix(1000, 0))
+		q := newTestQuery(t, &Config{BatchSize: 100, CollectInterval: time.Second, TimeoutInterval: time.Hour, Workers: 1,
+			Batcher: &testBatcher{}, Clock: clk})
+		start := clk.Now()
+		f := q.FetchAsync(3)
+		time.Sleep(20 * time.Millisecond)
+		if ready(f)() {
+			t.Fatal("batch flushed before collect interval")
+		}
+		advanceFor(t, clk, 100*time.Millisecond, ready(f))
+		if val, err := f.Result(); err != nil || val != 6 {
+			t.Fatalf("unexpected result %v %v", val, err)
+		}
+		if clk.Now().Sub(start) < time.Second {
+			t.Fatal("batch flushed before collect interval")
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		clk := NewFakeClock(time.Unix(1000, 0))
+		b := &testBatcher{delay: time.Hour}
+		q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Hour, Workers: 1, Batcher: b, Clock: clk})
+		f := q.FetchAsync(3)
+		done := make(chan error, 1)
+		go func() {
+			_, err := q.FetchTimeout(4, 5*time.Second)
+			done <- err
+		}()
+		var err error
+		advanceFor(t, clk, time.Second, func() bool {
+			select {
+			case err = <-done:
+				return true
+			default:
+				return false
+			}
+		})
+		if err != ErrTimeout {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if ready(f)() {
+			t.Fatal("request with long timeout finished")
+		}
+	})
+	t.Run("batch deadline", func(t *testing.T) {
+		// Batch context deadline must be in the clock time, not in real time.
+		clk := NewFakeClock(time.Unix(1000, 0))
+		q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Hour, Workers: 1, Batcher: &testBatcher{},
+			Clock: clk})
+		if val, err := q.Fetch(2); err != nil || val != 4 {
+			t.Fatalf("unexpected result %v %v", val, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if val, err := q.FetchContext(3, ctx); err != nil || val != 6 {
+			t.Fatalf("unexpected result %v %v", val, err)
+		}
+	})
+	t.Run("batch timeout", func(t *testing.T) {
+		clk := NewFakeClock(time.Unix(1000, 0))
+		q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Hour, BatchTimeout: time.Second, Workers: 1,
+			Batcher: &testBatcher{delay: time.Hour}, Clock: clk})
+		f := q.FetchAsync(3)
+		advanceFor(t, clk, 100*time.Millisecond, ready(f))
+		if _, err := f.Result(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("healthy", func(t *testing.T) {
+		clk := NewFakeClock(time.Unix(1000, 0))
+		q := newTestQuery(t, &Config{TimeoutInterval: time.Second, Workers: 1, Batcher: &blockingPinger{}, Clock: clk})
+		done := make(chan error, 1)
+		go func() { done <- q.Healthy() }()
+		var err error
+		advanceFor(t, clk, 100*time.Millisecond, func() bool {
+			select {
+			case err = <-done:
+				return true
+			default:
+				return false
+			}
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
+
+// Pinger that waits until context done.
+type blockingPinger struct {
+	testBatcher
+}
+
+func (p *blockingPinger) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,105 @@
+package batch_query
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+// Test batcher of int keys, returns doubled keys as values. Keys divisible by 10 are missing.
+type testBatcher struct {
+	mux     sync.Mutex
+	batches [][]any
+	// Delay of each batch, respects context.
+	delay time.Duration
+	// Optional error of the batch.
+	fail func(keys []any) error
+}
+
+func (b *testBatcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	b.mux.Lock()
+	b.batches = append(b.batches, append([]any(nil), keys...))
+	delay, fail := b.delay, b.fail
+	b.mux.Unlock()
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return dst, ctx.Err()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return dst, err
+	}
+	if fail != nil {
+		if err := fail(keys); err != nil {
+			return dst, err
+		}
+	}
+	for i := 0; i < len(keys); i++ {
+		if k := keys[i].(int); k%10 != 0 {
+			dst = append(dst, k*2)
+		}
+	}
+	return dst, nil
+}
+
+func (b *testBatcher) MatchKey(key, val any) bool {
+	return key.(int)*2 == val.(int)
+}
+
+// Get copy of processed batches.
+func (b *testBatcher) calls() [][]any {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return append([][]any(nil), b.batches...)
+}
+
+// Make query and close it at the end of the test.
+func newTestQuery(t testing.TB, conf *Config) *BatchQuery {
+	t.Helper()
+	q, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.ForceClose() })
+	return q
+}
+
+// Wait until condition becomes true.
+func waitFor(t testing.TB, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 5000; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition wasn't reached")
+}
+
+// Advance fake clock by step until condition becomes true.
+func advanceFor(t testing.TB, clk *FakeClock, step time.Duration, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if cond() {
+			return
+		}
+		clk.Advance(step)
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition wasn't reached")
+}
+
+func ready(f *Future) func() bool {
+	return func() bool {
+		_, err := f.Result()
+		return err != ErrNotReady
+	}
+}
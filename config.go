@@ -1,7 +1,9 @@
 package batch_query
 
 import (
+	"fmt"
 	"math"
+	"reflect"
 	"time"
 )
 
@@ -10,6 +12,9 @@ const (
 	defaultCollectInterval = time.Second
 	defaultTimeoutInterval = math.MaxInt64
 	defaultBuffer          = 16
+	defaultCacheEntries    = 1024
+
+	defaultWorkerIdleTimeout = 30 * time.Second
 )
 
 // Config describes query properties and behavior.
@@ -24,16 +29,78 @@ type Config struct {
 	// process even contains only one request.
 	// If this param omit defaultCollectInterval (1 second) will use instead.
 	CollectInterval time.Duration
+	// Adaptive batch size and collect interval settings.
+	// If set, BatchSize and CollectInterval are initial values, that adjusts between bounds according observed batch
+	// latency and fill ratio.
+	Adaptive *AdaptiveConfig
+	// Count of collecting buffer shards.
+	// Each shard has own lock, collects own batches and flushes them independently to the shared workers, so
+	// BatchSize and CollectInterval apply per shard. Useful to reduce lock contention under high load.
+	// If this param omit, single shard will use.
+	Shards uint
+	// How requests distribute among shards. See ShardByKey and ShardByProc.
+	ShardBy ShardBy
 	// How long request may wait collecting and processing. Must be greater that CollectInterval.
 	TimeoutInterval time.Duration
+	// Safety margin of deadline-aware flush, should cover expected batch latency.
+	// If set, collector tracks the earliest deadline of collected requests and flushes the batch with reason
+	// 'deadline' when waiting longer would break it, i.e. at the earliest deadline minus margin.
+	// Zero value disables deadline-aware flush.
+	DeadlineMargin time.Duration
+	// Max duration of single batch processing, including retries.
+	// Batcher gets context with deadline that is the earliest of the latest deadline of batch requests and
+	// BatchTimeout. Context also cancels when all requesters of the batch have gone.
+	// Zero value means no limit except deadlines of requests.
+	BatchTimeout time.Duration
 	// Internal workers count to process batches.
+	// In autoscaling mode it's an initial workers count.
 	Workers uint
+	// Bounds of workers count in autoscaling mode.
+	// If MaxWorkers is set, pool grows when all workers are busy, buffer is half full or latency of the last batch
+	// exceeds collect interval while buffer isn't empty. Pool shrinks by stopping workers idle longer than
+	// WorkerIdleTimeout. If MinWorkers omit, 1 will use instead.
+	MinWorkers uint
+	MaxWorkers uint
+	// How long worker may be idle before stopping in autoscaling mode.
+	// If this param omit defaultWorkerIdleTimeout (30 seconds) will use instead.
+	WorkerIdleTimeout time.Duration
 	// Internal buffer size to collect batches.
 	// If this param omit defaultBuffer (16) will use instead.
 	Buffer uint64
 	// Batch processor.
 	// Mandatory param.
 	Batcher Batcher
+	// Single flight mode.
+	// If enabled, request of the key that already processing by a worker doesn't go to the next batch, but waits for
+	// the response of the processing batch.
+	SingleFlight bool
+	// Result cache settings.
+	// If set, found values will be cached and requests of cached keys will be answered without batching.
+	Cache *CacheConfig
+	// Negative cache settings.
+	// If set, keys that wasn't found will be cached and their requests will be answered with ErrNotFound without
+	// batching. Use BatchQuery.Invalidate to remove keys from the cache when records are created.
+	NegativeCache *CacheConfig
+
+	// Retry policy of failed batches.
+	// If this param omit failed batches don't retry. See ExponentialRetry for built-in implementation.
+	RetryPolicy RetryPolicy
+	// Bisecting mode of failed batches.
+	// If enabled, failed batch splits into halves recursively until poison keys will be isolated. Thus, only poison
+	// keys get the error. Note, that in worst case (eg. storage is down) batch of N keys will take 2*N-1 requests.
+	Bisect bool
+	// Circuit breaker settings.
+	// If set, query switches to StatusThrottle when failure rate or latency of batches exceeds the thresholds. While
+	// throttled, requests fail fast with ErrThrottled, except probe requests that check the recovery of the storage.
+	Breaker *BreakerConfig
+	// Hedging settings.
+	// If set, batch that hasn't completed within hedge delay sends once again and the first successful response wins.
+	// Note, that hedging increases load of the storage.
+	Hedge *HedgeConfig
+
+	// Source of time, timers and tickers.
+	// If this param omit, SystemClock will use. See FakeClock for tests.
+	Clock Clock
 
 	// Metrics writer handler.
 	MetricsWriter MetricsWriter
@@ -44,5 +111,200 @@ type Config struct {
 
 func (c *Config) Copy() *Config {
 	cpy := *c
+	if c.Cache != nil {
+		cpy.Cache = c.Cache.Copy()
+	}
+	if c.NegativeCache != nil {
+		cpy.NegativeCache = c.NegativeCache.Copy()
+	}
+	if c.Breaker != nil {
+		cpy.Breaker = c.Breaker.Copy()
+	}
+	if c.Adaptive != nil {
+		cpy.Adaptive = c.Adaptive.Copy()
+	}
+	if c.Hedge != nil {
+		cpy.Hedge = c.Hedge.Copy()
+	}
 	return &cpy
 }
+
+// Check config params and fill omitted params with default values.
+func (c *Config) prepare() error {
+	if c.BatchSize == 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.CollectInterval <= 0 {
+		c.CollectInterval = defaultCollectInterval
+	}
+	if c.TimeoutInterval <= 0 {
+		c.TimeoutInterval = defaultTimeoutInterval
+	}
+	if c.TimeoutInterval < c.CollectInterval {
+		return ErrBadIntervals
+	}
+	if a := c.Adaptive; a != nil {
+		if a.MinBatchSize == 0 {
+			a.MinBatchSize = 1
+		}
+		if a.MaxBatchSize == 0 {
+			a.MaxBatchSize = c.BatchSize
+		}
+		if a.MinCollectInterval <= 0 {
+			a.MinCollectInterval = c.CollectInterval / 10
+		}
+		if a.MaxCollectInterval <= 0 {
+			a.MaxCollectInterval = c.CollectInterval
+		}
+		if a.MinBatchSize > a.MaxBatchSize || a.MinCollectInterval > a.MaxCollectInterval {
+			return ErrBadAdaptive
+		}
+		if c.TimeoutInterval < a.MaxCollectInterval {
+			return ErrBadIntervals
+		}
+	}
+
+	if c.Shards == 0 {
+		c.Shards = 1
+	}
+
+	if c.MaxWorkers > 0 {
+		if c.MinWorkers == 0 {
+			c.MinWorkers = 1
+		}
+		if c.MinWorkers > c.MaxWorkers {
+			return ErrBadWorkers
+		}
+		if c.Workers < c.MinWorkers {
+			c.Workers = c.MinWorkers
+		}
+		if c.Workers > c.MaxWorkers {
+			c.Workers = c.MaxWorkers
+		}
+	}
+	if c.Workers == 0 {
+		return ErrNoWorkers
+	}
+	if c.WorkerIdleTimeout <= 0 {
+		c.WorkerIdleTimeout = defaultWorkerIdleTimeout
+	}
+	if c.Buffer == 0 {
+		c.Buffer = defaultBuffer
+	}
+	if c.Batcher == nil {
+		return ErrNoBatcher
+	}
+
+	if c.Clock == nil {
+		c.Clock = SystemClock{}
+	}
+	if c.MetricsWriter == nil {
+		c.MetricsWriter = DummyMetrics{}
+	}
+	if c.Cache != nil && c.Cache.MaxEntries == 0 {
+		c.Cache.MaxEntries = defaultCacheEntries
+	}
+	if c.NegativeCache != nil && c.NegativeCache.MaxEntries == 0 {
+		c.NegativeCache.MaxEntries = defaultCacheEntries
+	}
+	if b := c.Breaker; b != nil {
+		if b.Window == 0 {
+			b.Window = defaultBreakerWindow
+		}
+		if b.OpenInterval <= 0 {
+			b.OpenInterval = defaultBreakerOpenInterval
+		}
+		if b.Probes == 0 {
+			b.Probes = defaultBreakerProbes
+		}
+	}
+	if h := c.Hedge; h != nil {
+		if h.Delay < 0 || h.Percentile < 0 || h.Percentile >= 1 || (h.Delay == 0 && h.Percentile == 0) {
+			return ErrBadHedge
+		}
+	}
+	return nil
+}
+
+// Check if params that can't be changed on the fly are equal to params of c.
+// Returns ErrNotLive wrapped with the name of the first mismatched param.
+func (c *Config) liveCompatible(n *Config) error {
+	switch {
+	case c.Shards != n.Shards:
+		return fmt.Errorf("%w: Shards", ErrNotLive)
+	case c.Buffer != n.Buffer:
+		return fmt.Errorf("%w: Buffer", ErrNotLive)
+	case !same(c.Batcher, n.Batcher):
+		return fmt.Errorf("%w: Batcher", ErrNotLive)
+	case !same(c.Clock, n.Clock):
+		return fmt.Errorf("%w: Clock", ErrNotLive)
+	case (c.Adaptive == nil) != (n.Adaptive == nil):
+		return fmt.Errorf("%w: Adaptive", ErrNotLive)
+	case !c.Cache.equal(n.Cache):
+		return fmt.Errorf("%w: Cache", ErrNotLive)
+	case !c.NegativeCache.equal(n.NegativeCache):
+		return fmt.Errorf("%w: NegativeCache", ErrNotLive)
+	case !c.Breaker.equal(n.Breaker):
+		return fmt.Errorf("%w: Breaker", ErrNotLive)
+	}
+	return nil
+}
+
+// Check if a and b are the same component.
+// Values that can't be compared safely using == (eg. structs with interface fields holding funcs) compare field by
+// field, and reference values (pointers, funcs, slices, maps, ...) compare by pointer, so copy of the config keeps
+// the same components.
+func same(a, b any) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if hashable(a) {
+		return a == b
+	}
+	return sameValue(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func sameValue(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Ptr, reflect.Chan, reflect.Func, reflect.Map, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	case reflect.Slice:
+		return a.Pointer() == b.Pointer() && a.Len() == b.Len()
+	case reflect.Interface:
+		return sameValue(a.Elem(), b.Elem())
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !sameValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !sameValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
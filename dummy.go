@@ -3,17 +3,33 @@ package batch_query
 import "time"
 
 // DummyMetrics is a stub metrics writer handler that uses by default and does nothing.
-// Need just to reduce checks in code.
+// Need just to reduce checks in code. Also implements MetricsWriterExt, so may be embedded into own writers.
 type DummyMetrics struct{}
 
-func (DummyMetrics) Fetch()                  {}
-func (DummyMetrics) OK(_ time.Duration)      {}
-func (DummyMetrics) Timeout()                {}
-func (DummyMetrics) Interrupt()              {}
-func (DummyMetrics) NotFound()               {}
-func (DummyMetrics) Fail()                   {}
-func (DummyMetrics) Batch()                  {}
-func (DummyMetrics) BatchOK(_ time.Duration) {}
-func (DummyMetrics) BatchFail()              {}
-func (DummyMetrics) BufferIn(_ string)       {}
-func (DummyMetrics) BufferOut()              {}
+func (DummyMetrics) Fetch()                          {}
+func (DummyMetrics) OK(_ time.Duration)              {}
+func (DummyMetrics) Timeout()                        {}
+func (DummyMetrics) Interrupt()                      {}
+func (DummyMetrics) NotFound()                       {}
+func (DummyMetrics) Fail()                           {}
+func (DummyMetrics) Throttle()                       {}
+func (DummyMetrics) Batch()                          {}
+func (DummyMetrics) BatchOK(_ time.Duration)         {}
+func (DummyMetrics) BatchFail()                      {}
+func (DummyMetrics) BatchRetry()                     {}
+func (DummyMetrics) Isolate()                        {}
+func (DummyMetrics) Hedge()                          {}
+func (DummyMetrics) HedgeWin()                       {}
+func (DummyMetrics) BufferIn(_ string)               {}
+func (DummyMetrics) BufferOut()                      {}
+func (DummyMetrics) WorkerUp()                       {}
+func (DummyMetrics) WorkerDown()                     {}
+func (DummyMetrics) Dedup(_ int)                     {}
+func (DummyMetrics) Prune(_ int)                     {}
+func (DummyMetrics) CacheHit()                       {}
+func (DummyMetrics) CacheMiss()                      {}
+func (DummyMetrics) CacheEvict(_ int)                {}
+func (DummyMetrics) Adapt(_ uint64, _ time.Duration) {}
+func (DummyMetrics) BreakerState(_ string)           {}
+
+var _ MetricsWriterExt = DummyMetrics{}
@@ -5,11 +5,18 @@ import "errors"
 var (
 	ErrNoConfig     = errors.New("no config provided")
 	ErrNoWorkers    = errors.New("no workers available")
+	ErrBadWorkers   = errors.New("bad workers bounds: min greater than max")
 	ErrNoBatcher    = errors.New("no batcher provided")
 	ErrBadIntervals = errors.New("bad intervals: timeout less that collect")
+	ErrBadAdaptive  = errors.New("bad adaptive bounds: min greater than max")
+	ErrBadHedge     = errors.New("bad hedge: delay or percentile required")
 	ErrQueryNil     = errors.New("query not initialized")
 	ErrQueryClosed  = errors.New("query closed")
 	ErrNotFound     = errors.New("record not found")
 	ErrInterrupt    = errors.New("interrupt")
 	ErrTimeout      = errors.New("timeout")
+	ErrNotReady     = errors.New("result not ready")
+	ErrMisaligned   = errors.New("batch result isn't aligned with keys")
+	ErrThrottled    = errors.New("query throttled")
+	ErrNotLive      = errors.New("param can't be changed on the fly")
 )
@@ -0,0 +1,68 @@
+package batch_query
+
+// flight represents in-flight key and requests attached to it.
+type flight struct {
+	id    any
+	pairs []pair
+	// Waiters of the batch that processes the key.
+	w *waiters
+}
+
+// Attach pair to in-flight key. Returns false if the key isn't in flight.
+func (q *BatchQuery) attach(p pair) bool {
+	if p.id == nil {
+		return false
+	}
+	q.fmux.Lock()
+	defer q.fmux.Unlock()
+	f, ok := q.flight[p.id]
+	if !ok || !p.watch(f.w) {
+		return false
+	}
+	f.pairs = append(f.pairs, p)
+	return true
+}
+
+// Register unique keys of the batch as in-flight.
+func (q *BatchQuery) takeoff(b *batch) {
+	b.flights = make([]*flight, len(b.keys))
+	q.fmux.Lock()
+	defer q.fmux.Unlock()
+	if q.flight == nil {
+		q.flight = make(map[any]*flight)
+	}
+	for i := 0; i < len(b.pairs); i++ {
+		id, pos := b.pairs[i].id, b.pos[i]
+		if id == nil || b.flights[pos] != nil {
+			continue
+		}
+		if _, ok := q.flight[id]; ok {
+			// Key is already in flight in another batch.
+			continue
+		}
+		f := &flight{id: id, w: b.w}
+		q.flight[id] = f
+		b.flights[pos] = f
+	}
+}
+
+// Unregister in-flight keys of the batch and send results to attached requesters.
+func (q *BatchQuery) land(b *batch, res []Result, err error) {
+	for i := 0; i < len(b.flights); i++ {
+		f := b.flights[i]
+		if f == nil {
+			continue
+		}
+		q.fmux.Lock()
+		delete(q.flight, f.id)
+		pairs := f.pairs
+		q.fmux.Unlock()
+		for j := 0; j < len(pairs); j++ {
+			if err != nil {
+				pairs[j].reply(nil, err)
+				continue
+			}
+			pairs[j].replyResult(&res[i])
+		}
+	}
+}
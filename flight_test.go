@@ -0,0 +1,30 @@
+package batch_query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSingleFlight(t *testing.T) {
+	for _, sf := range []bool{false, true} {
+		b := &testBatcher{delay: 50 * time.Millisecond}
+		q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Second, Workers: 2, Batcher: b,
+			SingleFlight: sf})
+		f := q.FetchAsync(1)
+		waitFor(t, func() bool { return len(b.calls()) == 1 })
+		// The same key while the first batch is in flight.
+		if val, err := q.Fetch(1); err != nil || val != 2 {
+			t.Fatalf("unexpected result %v %v", val, err)
+		}
+		if val, err := f.Wait(); err != nil || val != 2 {
+			t.Fatalf("unexpected result %v %v", val, err)
+		}
+		expect := 2
+		if sf {
+			expect = 1
+		}
+		if n := len(b.calls()); n != expect {
+			t.Fatalf("single flight %v: %d batches processed", sf, n)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package batch_query
 
+import (
+	"context"
+	"time"
+)
+
 type flushReason uint8
 
 const (
 	flushReasonSize flushReason = iota
 	flushReasonInterval
 	flushReasonForce
+	flushReasonDeadline
 )
 
 func (r flushReason) String() string {
@@ -16,24 +22,115 @@ func (r flushReason) String() string {
 		return "interval"
 	case flushReasonForce:
 		return "force"
+	case flushReasonDeadline:
+		return "deadline"
 	default:
 		return "unknown"
 	}
 }
 
-func (q *BatchQuery) flush(reason flushReason) {
-	q.mux.Lock()
-	defer q.mux.Unlock()
-	q.flushLF(reason)
+func (q *BatchQuery) flush(s *shard, reason flushReason) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	q.flushLF(s, reason)
 }
 
-func (q *BatchQuery) flushLF(reason flushReason) {
-	cpy := append([]pair(nil), q.buf...)
-	q.buf = q.buf[:0]
+func (q *BatchQuery) flushLF(s *shard, reason flushReason) {
+	if s.closed {
+		return
+	}
+	if q.pruneLF(s); len(s.buf) == 0 {
+		return
+	}
+	b := q.collectLF(s)
+	b.reason = reason
+	q.stats.flushed(reason, uint64(len(b.pairs)), q.batchSize())
 	q.mw().Batch()
 	if l := q.l(); l != nil {
 		l.Printf("flush by reason '%s'\n", reason.String())
 	}
-	q.c <- cpy
+	q.scale()
+	q.c <- b
 	q.mw().BufferIn(reason.String())
 }
+
+// Remove requests which requesters don't wait the response anymore from the shard buffer.
+// Returns count of removed requests.
+func (q *BatchQuery) pruneLF(s *shard) int {
+	buf := s.buf[:0]
+	for i := 0; i < len(s.buf); i++ {
+		if !s.buf[i].gone() {
+			buf = append(buf, s.buf[i])
+		}
+	}
+	n := len(s.buf) - len(buf)
+	for i := len(buf); i < len(s.buf); i++ {
+		s.buf[i] = pair{}
+	}
+	s.buf = buf
+	if n > 0 {
+		q.mw().Prune(n)
+	}
+	return n
+}
+
+// Make batch from the shard buffer. Duplicate keys collapses to one, so batcher will get each unique key once.
+func (q *BatchQuery) collectLF(s *shard) *batch {
+	b := batch{
+		keys:  make([]any, 0, len(s.buf)),
+		ids:   make([]any, 0, len(s.buf)),
+		pairs: append([]pair(nil), s.buf...),
+		pos:   make([]int, len(s.buf)),
+	}
+	s.buf = s.buf[:0]
+
+	var (
+		idx  map[any]int
+		nodl bool
+	)
+	for i := 0; i < len(b.pairs); i++ {
+		p := &b.pairs[i]
+		if p.dl.IsZero() {
+			nodl = true
+		} else if !nodl && p.dl.After(b.dl) {
+			b.dl = p.dl
+		}
+		if p.id != nil {
+			if idx == nil {
+				idx = make(map[any]int, len(b.pairs))
+			}
+			if j, ok := idx[p.id]; ok {
+				b.pos[i] = j
+				continue
+			}
+			idx[p.id] = len(b.keys)
+		}
+		b.pos[i] = len(b.keys)
+		b.keys = append(b.keys, p.key)
+		b.ids = append(b.ids, p.id)
+	}
+	if nodl {
+		b.dl = time.Time{}
+	}
+	if dup := len(b.pairs) - len(b.keys); dup > 0 {
+		q.mw().Dedup(dup)
+	}
+
+	// Batch context carries the latest deadline of requests and cancels when all requesters have gone.
+	var cancel context.CancelFunc
+	if b.dl.IsZero() {
+		b.ctx, cancel = context.WithCancel(q.wctx)
+	} else {
+		b.ctx, cancel = q.withDeadline(q.wctx, b.dl)
+	}
+	b.w = newWaiters(cancel)
+	for i := 0; i < len(b.pairs); i++ {
+		b.pairs[i].watch(b.w)
+	}
+	if q.cfg().SingleFlight {
+		q.takeoff(&b)
+	}
+	// Release collector's reference.
+	b.w.leave()
+	return &b
+}
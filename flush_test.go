@@ -0,0 +1,140 @@
+package batch_query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Key with interface field, comparable but may be unhashable.
+type ifaceKey struct {
+	X any
+}
+
+// Batcher of ifaceKey keys with []int values.
+type ifaceBatcher struct{}
+
+func (ifaceBatcher) Batch(dst []any, keys []any, _ context.Context) ([]any, error) {
+	return append(dst, keys...), nil
+}
+
+func (ifaceBatcher) MatchKey(key, val any) bool {
+	return key.(ifaceKey).X.([]int)[0] == val.(ifaceKey).X.([]int)[0]
+}
+
+func TestDedup(t *testing.T) {
+	t.Run("collapse", func(t *testing.T) {
+		b := &testBatcher{}
+		q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Second, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: b})
+		vals, errs := q.FetchMany([]any{1, 2, 1, 2})
+		for i, k := range []int{1, 2, 1, 2} {
+			if vals[i] != k*2 || errs[i] != nil {
+				t.Fatalf("unexpected result %v %v", vals[i], errs[i])
+			}
+		}
+		if calls := b.calls(); len(calls) != 1 || len(calls[0]) != 2 {
+			t.Fatalf("unexpected batches %v", calls)
+		}
+		if n := q.Stats().Dedup; n != 2 {
+			t.Fatalf("unexpected dedup count %d", n)
+		}
+	})
+	t.Run("unhashable", func(t *testing.T) {
+		// Keys with unhashable dynamic values mustn't panic and aren't deduplicated.
+		q := newTestQuery(t, &Config{BatchSize: 2, CollectInterval: 10 * time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Shards: 2, Batcher: ifaceBatcher{}})
+		keys := []any{ifaceKey{X: []int{1}}, ifaceKey{X: []int{1}}}
+		q.FetchAsync(keys[0])
+		vals, errs := q.FetchMany(keys)
+		for i := 0; i < len(keys); i++ {
+			if vals[i] == nil || errs[i] != nil {
+				t.Fatalf("unexpected result %v %v", vals[i], errs[i])
+			}
+		}
+	})
+}
+
+func TestPrune(t *testing.T) {
+	clk := NewFakeClock(time.Now())
+	b := &testBatcher{}
+	q := newTestQuery(t, &Config{BatchSize: 2, CollectInterval: time.Second, TimeoutInterval: 2 * time.Second,
+		Workers: 1, Batcher: b, Clock: clk})
+	buffered := func(n int) func() bool {
+		return func() bool {
+			s := q.shards[0]
+			s.mux.Lock()
+			defer s.mux.Unlock()
+			return len(s.buf) == n
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := q.FetchContext(1, ctx)
+		done <- err
+	}()
+	waitFor(t, buffered(1))
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("cancelled request succeeded")
+	}
+	// Cancelled request frees place in the batch, so batch isn't full yet.
+	f := q.FetchAsync(2)
+	waitFor(t, buffered(1))
+	if n := q.Stats().Prune; n != 1 {
+		t.Fatalf("unexpected prune count %d", n)
+	}
+	if v, err := q.FetchAsync(3).Wait(); v != 6 || err != nil {
+		t.Fatalf("unexpected result %v %v", v, err)
+	}
+	if v, err := f.Wait(); v != 4 || err != nil {
+		t.Fatalf("unexpected result %v %v", v, err)
+	}
+	if calls := b.calls(); len(calls) != 1 || len(calls[0]) != 2 {
+		t.Fatalf("unexpected batches %v", calls)
+	}
+}
+
+func TestDeadlineFlush(t *testing.T) {
+	conf := Config{BatchSize: 100, CollectInterval: 10 * time.Second, TimeoutInterval: 20 * time.Second,
+		DeadlineMargin: 100 * time.Millisecond, Workers: 1, Batcher: &testBatcher{}}
+	t.Run("timer", func(t *testing.T) {
+		clk := NewFakeClock(time.Now())
+		c := conf
+		c.Clock = clk
+		q := newTestQuery(t, &c)
+		start := clk.Now()
+		var v any
+		done := make(chan error, 1)
+		go func() {
+			var err error
+			v, err = q.FetchTimeout(1, time.Second)
+			done <- err
+		}()
+		// Batch flushes at the deadline minus margin, long before collect interval end.
+		advanceFor(t, clk, 100*time.Millisecond, func() bool { return len(done) > 0 })
+		if err := <-done; v != 2 || err != nil {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if d := clk.Now().Sub(start); d > time.Second {
+			t.Fatalf("flushed too late %s", d)
+		}
+		if n := q.Stats().Flush.Deadline; n != 1 {
+			t.Fatalf("unexpected deadline flushes %d", n)
+		}
+	})
+	t.Run("immediate", func(t *testing.T) {
+		// Deadline within the margin flushes batch on push.
+		c := conf
+		c.Clock = NewFakeClock(time.Now())
+		q := newTestQuery(t, &c)
+		if v, err := q.FetchTimeout(1, 50*time.Millisecond); v != 2 || err != nil {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if n := q.Stats().Flush.Deadline; n != 1 {
+			t.Fatalf("unexpected deadline flushes %d", n)
+		}
+	})
+}
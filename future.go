@@ -0,0 +1,147 @@
+package batch_query
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	futureStatusPending = iota
+	futureStatusResolved
+	futureStatusAbandoned
+)
+
+// Future represents pending response of single asynchronous request.
+// See BatchQuery.FetchAsync.
+type Future struct {
+	q     *BatchQuery
+	s     uint32
+	start time.Time
+	// Deadline of the request, zero value means no deadline.
+	dl   time.Time
+	done chan struct{}
+	// Waiters of the batch that contains the request.
+	mux sync.Mutex
+	w   *waiters
+
+	val any
+	err error
+}
+
+func newFuture(q *BatchQuery, start, deadline time.Time) *Future {
+	f := Future{
+		q:     q,
+		start: start,
+		dl:    deadline,
+		done:  make(chan struct{}),
+	}
+	return &f
+}
+
+// Make future already resolved with given error.
+func failedFuture(err error) *Future {
+	f := Future{
+		s:    futureStatusResolved,
+		err:  err,
+		done: make(chan struct{}),
+	}
+	close(f.done)
+	return &f
+}
+
+// Done returns a channel that's closed when response is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until response will available or timeout interval of the query reached.
+func (f *Future) Wait() (any, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	default:
+	}
+	if f.dl.IsZero() {
+		<-f.done
+		return f.val, f.err
+	}
+	remain := f.dl.Sub(f.q.now())
+	if remain <= 0 {
+		return f.abandon(ctxTO)
+	}
+	t := f.q.clock().NewTimer(remain)
+	defer t.Stop()
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-t.C():
+		return f.abandon(ctxTO)
+	}
+}
+
+// WaitContext blocks until response will available or context done.
+func (f *Future) WaitContext(ctx context.Context) (any, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return f.abandon(ctxInt)
+	}
+}
+
+// Result returns response without blocking.
+// If response isn't available yet, ErrNotReady will return.
+func (f *Future) Result() (any, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	default:
+		return nil, ErrNotReady
+	}
+}
+
+// Set the response and notify waiters.
+func (f *Future) resolve(val any, err error) {
+	if !atomic.CompareAndSwapUint32(&f.s, futureStatusPending, futureStatusResolved) {
+		return
+	}
+	f.val, f.err = val, err
+	f.q.observe(tuple{val: val, err: err}, f.start)
+	close(f.done)
+}
+
+// Give up waiting the response.
+// If response has been set concurrently, it will return instead of timeout/interrupt error.
+func (f *Future) abandon(ctxt uint8) (any, error) {
+	if !atomic.CompareAndSwapUint32(&f.s, futureStatusPending, futureStatusAbandoned) {
+		<-f.done
+		return f.val, f.err
+	}
+	f.err = f.q.abort(ctxt)
+	close(f.done)
+	f.mux.Lock()
+	w := f.w
+	f.w = nil
+	f.mux.Unlock()
+	if w != nil {
+		w.leave()
+	}
+	return nil, f.err
+}
+
+// Register the request in batch waiters.
+// Returns false if all waiters of the batch have already gone.
+func (f *Future) watch(w *waiters) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if atomic.LoadUint32(&f.s) == futureStatusAbandoned {
+		return true
+	}
+	if !w.join() {
+		return false
+	}
+	f.w = w
+	return true
+}
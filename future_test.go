@@ -0,0 +1,52 @@
+package batch_query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFetchAsync(t *testing.T) {
+	t.Run("wait", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+			Workers: 1, Batcher: &testBatcher{}})
+		fs := []*Future{q.FetchAsync(1), q.FetchAsync(2), q.FetchAsync(10)}
+		for i, k := range []int{1, 2} {
+			if val, err := fs[i].Wait(); err != nil || val != k*2 {
+				t.Fatalf("unexpected result %v %v", val, err)
+			}
+		}
+		if _, err := fs[2].Wait(); err != ErrNotFound {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("result", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Hour, TimeoutInterval: time.Hour,
+			Workers: 1, Batcher: &testBatcher{}})
+		f := q.FetchAsync(1)
+		if _, err := f.Result(); err != ErrNotReady {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Hour, TimeoutInterval: time.Hour,
+			Workers: 1, Batcher: &testBatcher{}})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		f := q.FetchAsync(1)
+		if _, err := f.WaitContext(ctx); err != ErrInterrupt {
+			t.Fatalf("unexpected error %v", err)
+		}
+		// Abandoned future keeps the error.
+		if _, err := f.Result(); err != ErrInterrupt {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("closed", func(t *testing.T) {
+		q := newTestQuery(t, &Config{Workers: 1, Batcher: &testBatcher{}})
+		_ = q.Close()
+		if _, err := q.FetchAsync(1).Wait(); err != ErrQueryClosed {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package batch_query
+
+import (
+	"context"
+	"time"
+)
+
+// Min count of observed batches to calculate latency percentile.
+const hedgeMinSamples = 100
+
+// HedgeConfig describes hedging of slow batches.
+// If batch hasn't completed within the delay, the same keys are sent again, possibly through another batcher. The
+// first successful response wins and the other request cancels through its context.
+type HedgeConfig struct {
+	// Delay of hedged request.
+	// If Percentile is set, it's a min delay, that also uses until enough batches will be observed.
+	Delay time.Duration
+	// Percentile of batch latency in range (0..1) to use as delay of hedged request, eg. 0.95.
+	// Zero value disables percentile and Delay uses as is.
+	Percentile float64
+	// Batcher of hedged requests.
+	// If this param omit, Config.Batcher will use.
+	Batcher Batcher
+}
+
+func (c *HedgeConfig) Copy() *HedgeConfig {
+	cpy := *c
+	return &cpy
+}
+
+// Outcome of one of hedged requests.
+type hedgeOutcome struct {
+	res   []Result
+	err   error
+	hedge bool
+}
+
+// Exec batch operation and send hedged request if it hasn't completed within hedge delay.
+func (q *BatchQuery) hedge(c *Config, keys, ids []any, ctx context.Context) ([]Result, error) {
+	h := c.Hedge
+	// Identities of keys are made by the main batcher, so hedge batcher must calculate its own ones.
+	hb, hids := h.Batcher, []any(nil)
+	if hb == nil {
+		hb, hids = c.Batcher, ids
+	}
+	delay, ok := q.hedgeDelay(h)
+	if !ok {
+		return batchBy(c.Batcher, keys, ids, ctx)
+	}
+
+	oc := make(chan hedgeOutcome, 2)
+	exec := func(b Batcher, ids []any, hedge bool) context.CancelFunc {
+		bctx, cancel := context.WithCancel(ctx)
+		go func() {
+			res, err := batchBy(b, keys, ids, bctx)
+			oc <- hedgeOutcome{res: res, err: err, hedge: hedge}
+		}()
+		return cancel
+	}
+	cancel := []context.CancelFunc{exec(c.Batcher, ids, false)}
+	defer func() {
+		// Cancel the loser.
+		for i := 0; i < len(cancel); i++ {
+			cancel[i]()
+		}
+	}()
+
+	t := q.clock().NewTimer(delay)
+	defer t.Stop()
+	tc := t.C()
+	var (
+		primary hedgeOutcome
+		pending = 1
+	)
+	for {
+		select {
+		case <-tc:
+			tc = nil
+			q.mw().Hedge()
+			if l := q.l(); l != nil {
+				l.Printf("batch of %d keys hasn't completed within %s, send hedged request\n", len(keys), delay)
+			}
+			cancel = append(cancel, exec(hb, hids, true))
+			pending++
+		case o := <-oc:
+			pending--
+			if o.err == nil {
+				if o.hedge {
+					q.mw().HedgeWin()
+				}
+				return o.res, nil
+			}
+			if len(cancel) == 1 {
+				// Request failed before hedge delay, nothing to wait.
+				return o.res, o.err
+			}
+			if !o.hedge {
+				primary = o
+			}
+			if pending == 0 {
+				// Both requests failed, report the error of the original one.
+				return primary.res, primary.err
+			}
+		}
+	}
+}
+
+// Get delay of hedged request.
+// Returns false if delay can't be calculated yet.
+func (q *BatchQuery) hedgeDelay(h *HedgeConfig) (time.Duration, bool) {
+	if h.Percentile > 0 {
+		if d, n := q.stats.blat.quantile(h.Percentile); n >= hedgeMinSamples && d > h.Delay {
+			return d, true
+		}
+	}
+	return h.Delay, h.Delay > 0
+}
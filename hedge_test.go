@@ -0,0 +1,85 @@
+package batch_query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHedge(t *testing.T) {
+	conf := Config{BatchSize: 1, CollectInterval: time.Second, TimeoutInterval: 2 * time.Second, Workers: 1}
+	t.Run("win", func(t *testing.T) {
+		slow := ctxBatcher{testBatcher: &testBatcher{delay: 10 * time.Second}, ctxs: make(chan context.Context, 1)}
+		fast := &testBatcher{}
+		c := conf
+		c.Batcher = slow
+		c.Hedge = &HedgeConfig{Delay: 10 * time.Millisecond, Batcher: fast}
+		q := newTestQuery(t, &c)
+		if v, err := q.Fetch(1); v != 2 || err != nil {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if st := q.Stats(); st.Hedge != 1 || st.HedgeWin != 1 {
+			t.Fatalf("unexpected hedge stats %d %d", st.Hedge, st.HedgeWin)
+		}
+		if len(fast.calls()) != 1 {
+			t.Fatal("hedge batcher wasn't called")
+		}
+		// The loser cancels.
+		ctx := <-slow.ctxs
+		waitFor(t, func() bool { return ctx.Err() != nil })
+	})
+	t.Run("fast", func(t *testing.T) {
+		b := &testBatcher{}
+		c := conf
+		c.Batcher = b
+		c.Hedge = &HedgeConfig{Delay: time.Second}
+		q := newTestQuery(t, &c)
+		if v, err := q.Fetch(1); v != 2 || err != nil {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if n := q.Stats().Hedge; n != 0 {
+			t.Fatalf("unexpected hedge count %d", n)
+		}
+		if len(b.calls()) != 1 {
+			t.Fatal("hedged request sent")
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		// Error of hedged request doesn't override the error of the original one.
+		c := conf
+		c.Batcher = &testBatcher{delay: 50 * time.Millisecond, fail: func([]any) error { return errTest }}
+		c.Hedge = &HedgeConfig{Delay: 10 * time.Millisecond, Batcher: &testBatcher{fail: func([]any) error {
+			return context.Canceled
+		}}}
+		q := newTestQuery(t, &c)
+		if _, err := q.Fetch(1); err != errTest {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("bad config", func(t *testing.T) {
+		for _, h := range []HedgeConfig{{}, {Delay: -1}, {Percentile: 1}, {Percentile: -.5}} {
+			c := conf
+			c.Batcher = &testBatcher{}
+			c.Hedge = &h
+			if _, err := New(&c); err != ErrBadHedge {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+	})
+	t.Run("percentile", func(t *testing.T) {
+		c := conf
+		c.Batcher = &testBatcher{}
+		q := newTestQuery(t, &c)
+		h := &HedgeConfig{Delay: time.Millisecond, Percentile: .9}
+		// Min delay uses until enough batches observed.
+		if d, ok := q.hedgeDelay(h); d != time.Millisecond || !ok {
+			t.Fatalf("unexpected delay %s", d)
+		}
+		for i := 0; i < hedgeMinSamples; i++ {
+			q.stats.blat.observe(100 * time.Millisecond)
+		}
+		if d, _ := q.hedgeDelay(h); d < 80*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("unexpected delay %s", d)
+		}
+	})
+}
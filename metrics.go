@@ -28,3 +28,38 @@ type MetricsWriter interface {
 	// BufferOut registers outcoming of batch from internal buffer.
 	BufferOut()
 }
+
+// MetricsWriterExt is an optional extension of MetricsWriter with hooks of additional query features.
+// Query checks if configured metrics writer implements it, otherwise extended metrics are skipped. Embed DummyMetrics
+// to implement unnecessary hooks.
+type MetricsWriterExt interface {
+	MetricsWriter
+	// Throttle registers single request rejected by circuit breaker.
+	Throttle()
+	// BatchRetry registers retry of failed batch.
+	BatchRetry()
+	// Isolate registers single key isolated by bisecting of failed batch.
+	Isolate()
+	// Hedge registers hedged request of slow batch.
+	Hedge()
+	// HedgeWin registers hedged request that completed before the original one.
+	HedgeWin()
+	// WorkerUp registers start of the worker.
+	WorkerUp()
+	// WorkerDown registers stop of the worker.
+	WorkerDown()
+	// Dedup registers count of duplicate requests collapsed in the batch.
+	Dedup(count int)
+	// Prune registers count of cancelled requests removed from the batch.
+	Prune(count int)
+	// CacheHit registers single request answered from the cache.
+	CacheHit()
+	// CacheMiss registers single request that wasn't found in the cache.
+	CacheMiss()
+	// CacheEvict registers count of entries evicted from the cache.
+	CacheEvict(count int)
+	// Adapt registers new effective batch size and collect interval.
+	Adapt(batchSize uint64, collectInterval time.Duration)
+	// BreakerState registers switching of circuit breaker to new state.
+	BreakerState(state string)
+}
@@ -10,6 +10,9 @@ const (
 	single = "single"
 	batch  = "batch"
 	buffer = "buffer"
+	cache  = "cache"
+	brkr   = "breaker"
+	worker = "worker"
 
 	ioIn   = "in"
 	ioOK   = "success"
@@ -17,6 +20,16 @@ const (
 	ioInt  = "interrupt"
 	io404  = "not_found"
 	ioFail = "fail"
+	ioDup  = "dedup"
+	ioHit  = "hit"
+	ioMiss = "miss"
+	ioEvct = "evict"
+	ioRtr  = "retry"
+	ioIsol = "isolate"
+	ioThr  = "throttle"
+	ioPrn  = "prune"
+	ioHdg  = "hedge"
+	ioHdgW = "hedge_win"
 )
 
 type Writer interface {
@@ -26,11 +39,25 @@ type Writer interface {
 	Timeout()
 	Interrupt()
 	Fail()
+	Throttle()
 	Batch()
 	BatchOK(duration time.Duration)
 	BatchFail()
+	BatchRetry()
+	Isolate()
+	Hedge()
+	HedgeWin()
 	BufferIn(reason string)
 	BufferOut()
+	WorkerUp()
+	WorkerDown()
+	Dedup(count int)
+	Prune(count int)
+	CacheHit()
+	CacheMiss()
+	CacheEvict(count int)
+	Adapt(batchSize uint64, collectInterval time.Duration)
+	BreakerState(state string)
 }
 
 // writer is a Prometheus implementation of batch_query.MetricsWriter.
@@ -45,6 +72,7 @@ var (
 	promIO     *prometheus.CounterVec
 	promBufIO  *prometheus.CounterVec
 	promTiming *prometheus.HistogramVec
+	promAdapt  *prometheus.GaugeVec
 )
 
 func NewWriter(name string, options ...Option) Writer {
@@ -98,7 +126,12 @@ func init() {
 		Buckets: buckets,
 	}, []string{"query", "entity"})
 
-	prometheus.MustRegister(promSize, promFlush, promIO, promBufIO, promTiming)
+	promAdapt = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "batch_query_adaptive",
+		Help: "Effective values of adaptive params.",
+	}, []string{"query", "param"})
+
+	prometheus.MustRegister(promSize, promFlush, promIO, promBufIO, promTiming, promAdapt)
 }
 
 func (m writer) Fetch() {
@@ -132,6 +165,10 @@ func (m writer) Fail() {
 	promIO.WithLabelValues(m.name, single, ioFail).Inc()
 }
 
+func (m writer) Throttle() {
+	promIO.WithLabelValues(m.name, single, ioThr).Inc()
+}
+
 func (m writer) Batch() {
 	promSize.WithLabelValues(m.name, batch).Inc()
 	promIO.WithLabelValues(m.name, batch, ioIn).Inc()
@@ -148,6 +185,22 @@ func (m writer) BatchFail() {
 	promIO.WithLabelValues(m.name, batch, ioFail).Inc()
 }
 
+func (m writer) BatchRetry() {
+	promIO.WithLabelValues(m.name, batch, ioRtr).Inc()
+}
+
+func (m writer) Isolate() {
+	promIO.WithLabelValues(m.name, single, ioIsol).Inc()
+}
+
+func (m writer) Hedge() {
+	promIO.WithLabelValues(m.name, batch, ioHdg).Inc()
+}
+
+func (m writer) HedgeWin() {
+	promIO.WithLabelValues(m.name, batch, ioHdgW).Inc()
+}
+
 func (m writer) BufferIn(reason string) {
 	promSize.WithLabelValues(m.name, buffer).Inc()
 	promFlush.WithLabelValues(m.name, reason)
@@ -158,3 +211,40 @@ func (m writer) BufferOut() {
 	promSize.WithLabelValues(m.name, buffer).Dec()
 	promBufIO.WithLabelValues(m.name).Add(-1)
 }
+
+func (m writer) WorkerUp() {
+	promSize.WithLabelValues(m.name, worker).Inc()
+}
+
+func (m writer) WorkerDown() {
+	promSize.WithLabelValues(m.name, worker).Dec()
+}
+
+func (m writer) Dedup(count int) {
+	promIO.WithLabelValues(m.name, single, ioDup).Add(float64(count))
+}
+
+func (m writer) Prune(count int) {
+	promIO.WithLabelValues(m.name, single, ioPrn).Add(float64(count))
+}
+
+func (m writer) CacheHit() {
+	promIO.WithLabelValues(m.name, cache, ioHit).Inc()
+}
+
+func (m writer) CacheMiss() {
+	promIO.WithLabelValues(m.name, cache, ioMiss).Inc()
+}
+
+func (m writer) CacheEvict(count int) {
+	promIO.WithLabelValues(m.name, cache, ioEvct).Add(float64(count))
+}
+
+func (m writer) Adapt(batchSize uint64, collectInterval time.Duration) {
+	promAdapt.WithLabelValues(m.name, "batch_size").Set(float64(batchSize))
+	promAdapt.WithLabelValues(m.name, "collect_interval").Set(float64(collectInterval / m.prec))
+}
+
+func (m writer) BreakerState(state string) {
+	promIO.WithLabelValues(m.name, brkr, state).Inc()
+}
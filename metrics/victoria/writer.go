@@ -10,6 +10,9 @@ const (
 	single = "single"
 	batch  = "batch"
 	buffer = "buffer"
+	cache  = "cache"
+	brkr   = "breaker"
+	worker = "worker"
 
 	ioIn   = "in"
 	ioOK   = "success"
@@ -17,6 +20,16 @@ const (
 	ioInt  = "interrupt"
 	io404  = "not_found"
 	ioFail = "fail"
+	ioDup  = "dedup"
+	ioHit  = "hit"
+	ioMiss = "miss"
+	ioEvct = "evict"
+	ioRtr  = "retry"
+	ioIsol = "isolate"
+	ioThr  = "throttle"
+	ioPrn  = "prune"
+	ioHdg  = "hedge"
+	ioHdgW = "hedge_win"
 )
 
 type Writer interface {
@@ -26,11 +39,25 @@ type Writer interface {
 	Timeout()
 	Interrupt()
 	Fail()
+	Throttle()
 	Batch()
 	BatchOK(duration time.Duration)
 	BatchFail()
+	BatchRetry()
+	Isolate()
+	Hedge()
+	HedgeWin()
 	BufferIn(reason string)
 	BufferOut()
+	WorkerUp()
+	WorkerDown()
+	Dedup(count int)
+	Prune(count int)
+	CacheHit()
+	CacheMiss()
+	CacheEvict(count int)
+	Adapt(batchSize uint64, collectInterval time.Duration)
+	BreakerState(state string)
 }
 
 // writer is a VictoriaMetrics implementation of batch_query.MetricsWriter.
@@ -84,6 +111,10 @@ func (m writer) Fail() {
 	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", single).WithLabel("type", ioFail).Inc()
 }
 
+func (m writer) Throttle() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", single).WithLabel("type", ioThr).Inc()
+}
+
 func (m writer) Batch() {
 	vmchain.Gauge("batch_query_size", nil).WithLabel("query", m.name).WithLabel("entity", batch).Inc()
 	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", batch).WithLabel("type", ioIn).Inc()
@@ -100,6 +131,22 @@ func (m writer) BatchFail() {
 	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", batch).WithLabel("type", ioFail).Inc()
 }
 
+func (m writer) BatchRetry() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", batch).WithLabel("type", ioRtr).Inc()
+}
+
+func (m writer) Isolate() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", single).WithLabel("type", ioIsol).Inc()
+}
+
+func (m writer) Hedge() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", batch).WithLabel("type", ioHdg).Inc()
+}
+
+func (m writer) HedgeWin() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", batch).WithLabel("type", ioHdgW).Inc()
+}
+
 func (m writer) BufferIn(reason string) {
 	vmchain.Gauge("batch_query_size", nil).WithLabel("query", m.name).WithLabel("entity", buffer).Inc()
 	vmchain.Gauge("batch_query_flush", nil).WithLabel("query", m.name).WithLabel("reason", reason).Inc()
@@ -111,4 +158,41 @@ func (m writer) BufferOut() {
 	vmchain.Counter("batch_query_bufio").WithLabel("query", m.name).Inc()
 }
 
+func (m writer) WorkerUp() {
+	vmchain.Gauge("batch_query_size", nil).WithLabel("query", m.name).WithLabel("entity", worker).Inc()
+}
+
+func (m writer) WorkerDown() {
+	vmchain.Gauge("batch_query_size", nil).WithLabel("query", m.name).WithLabel("entity", worker).Dec()
+}
+
+func (m writer) Dedup(count int) {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", single).WithLabel("type", ioDup).Add(count)
+}
+
+func (m writer) Prune(count int) {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", single).WithLabel("type", ioPrn).Add(count)
+}
+
+func (m writer) CacheHit() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", cache).WithLabel("type", ioHit).Inc()
+}
+
+func (m writer) CacheMiss() {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", cache).WithLabel("type", ioMiss).Inc()
+}
+
+func (m writer) CacheEvict(count int) {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", cache).WithLabel("type", ioEvct).Add(count)
+}
+
+func (m writer) Adapt(batchSize uint64, collectInterval time.Duration) {
+	vmchain.Gauge("batch_query_adaptive", nil).WithLabel("query", m.name).WithLabel("param", "batch_size").Set(float64(batchSize))
+	vmchain.Gauge("batch_query_adaptive", nil).WithLabel("query", m.name).WithLabel("param", "collect_interval").Set(float64(collectInterval / m.prec))
+}
+
+func (m writer) BreakerState(state string) {
+	vmchain.Counter("batch_query_io").WithLabel("query", m.name).WithLabel("entity", brkr).WithLabel("type", state).Inc()
+}
+
 var _ = NewWriter
@@ -16,6 +16,16 @@ type Batcher struct {
 }
 
 func (b Batcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	return b.batch(dst, keys, false, ctx)
+}
+
+// BatchOrdered processes collected batch and returns records aligned with keys.
+// Missing records have nil values.
+func (b Batcher) BatchOrdered(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	return b.batch(dst, keys, true, ctx)
+}
+
+func (b Batcher) batch(dst []any, keys []any, ordered bool, ctx context.Context) ([]any, error) {
 	if len(b.Namespace) == 0 {
 		return dst, ErrNoNS
 	}
@@ -32,10 +42,29 @@ func (b Batcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, error
 		return dst, ErrNoClient
 	}
 	var err error
-	dst, err = fetch(b.Client, b.Policy, b.Namespace, b.SetName, b.Bins, dst, keys, ctx)
+	dst, err = fetch(b.Client, b.Policy, b.Namespace, b.SetName, b.Bins, dst, keys, ordered, ctx)
 	return dst, err
 }
 
+// Ping checks if client is connected to the cluster.
+func (b Batcher) Ping(_ context.Context) error {
+	if b.Client == nil {
+		return ErrNoClient
+	}
+	if !b.Client.IsConnected() {
+		return ErrNoConn
+	}
+	return nil
+}
+
 func (b Batcher) MatchKey(key, val any) bool {
 	return matchKey(key, val, b.Namespace, b.SetName)
 }
+
+func (b Batcher) KeyIdent(key any) any {
+	return keyIdent(key, b.Namespace, b.SetName)
+}
+
+func (b Batcher) ValueIdent(val any) any {
+	return valueIdent(val)
+}
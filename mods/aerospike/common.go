@@ -6,7 +6,9 @@ import (
 	as "github.com/aerospike/aerospike-client-go"
 )
 
-func fetch(cln *as.Client, pol *as.BatchPolicy, ns, set string, bins []string, dst []any, keys []any, _ context.Context) ([]any, error) {
+// Fetch records of keys. Missing records are skipped, but in ordered mode they represent as nil values to keep
+// alignment with keys.
+func fetch(cln *as.Client, pol *as.BatchPolicy, ns, set string, bins []string, dst []any, keys []any, ordered bool, _ context.Context) ([]any, error) {
 	askeys := make([]*as.Key, 0, len(keys))
 	for i := 0; i < len(keys); i++ {
 		var (
@@ -18,7 +20,7 @@ func fetch(cln *as.Client, pol *as.BatchPolicy, ns, set string, bins []string, d
 			ask = x
 		default:
 			if ask, err = as.NewKey(ns, set, keys[i]); err != nil {
-				return dst, nil
+				return dst, err
 			}
 		}
 		askeys = append(askeys, ask)
@@ -28,6 +30,12 @@ func fetch(cln *as.Client, pol *as.BatchPolicy, ns, set string, bins []string, d
 		return dst, err
 	}
 	for i := 0; i < len(records); i++ {
+		if records[i] == nil {
+			if ordered {
+				dst = append(dst, nil)
+			}
+			continue
+		}
 		dst = append(dst, records[i])
 	}
 	return dst, nil
@@ -59,3 +67,40 @@ func matchKey(key, val any, ns, set string) bool {
 	}
 	return ask.Equals(asv)
 }
+
+// Digest-based identity of the key.
+type ident [20]byte
+
+func keyIdent(key any, ns, set string) any {
+	var ask *as.Key
+	switch x := key.(type) {
+	case *as.Key:
+		ask = x
+	default:
+		ask, _ = as.NewKey(ns, set, key)
+	}
+	if ask == nil {
+		return nil
+	}
+	var id ident
+	copy(id[:], ask.Digest())
+	return id
+}
+
+func valueIdent(val any) any {
+	var asv *as.Key
+	switch x := val.(type) {
+	case *as.Key:
+		asv = x
+	case *as.Record:
+		if x != nil {
+			asv = x.Key
+		}
+	}
+	if asv == nil {
+		return nil
+	}
+	var id ident
+	copy(id[:], asv.Digest())
+	return id
+}
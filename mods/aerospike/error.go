@@ -9,4 +9,5 @@ var (
 	ErrNoPolicy  = errors.New("no batch policy provided")
 	ErrNoClient  = errors.New("no client provided")
 	ErrNoClients = errors.New("no clients provided")
+	ErrNoConn    = errors.New("no connection to the cluster")
 )
@@ -20,6 +20,16 @@ type MCBatcher struct {
 }
 
 func (b MCBatcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	return b.batch(dst, keys, false, ctx)
+}
+
+// BatchOrdered processes collected batch and returns records aligned with keys.
+// Missing records have nil values.
+func (b MCBatcher) BatchOrdered(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	return b.batch(dst, keys, true, ctx)
+}
+
+func (b MCBatcher) batch(dst []any, keys []any, ordered bool, ctx context.Context) ([]any, error) {
 	if len(b.Namespace) == 0 {
 		return dst, ErrNoNS
 	}
@@ -40,10 +50,31 @@ func (b MCBatcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, err
 		return dst, ErrNoClient
 	}
 	var err error
-	dst, err = fetch(cln, b.Policy, b.Namespace, b.SetName, b.Bins, dst, keys, ctx)
+	dst, err = fetch(cln, b.Policy, b.Namespace, b.SetName, b.Bins, dst, keys, ordered, ctx)
 	return dst, err
 }
 
+// Ping checks if at least one client is connected to the cluster.
+func (b MCBatcher) Ping(_ context.Context) error {
+	if len(b.Clients) == 0 {
+		return ErrNoClients
+	}
+	for i := 0; i < len(b.Clients); i++ {
+		if b.Clients[i] != nil && b.Clients[i].IsConnected() {
+			return nil
+		}
+	}
+	return ErrNoConn
+}
+
 func (b MCBatcher) MatchKey(key, val any) bool {
 	return matchKey(key, val, b.Namespace, b.SetName)
 }
+
+func (b MCBatcher) KeyIdent(key any) any {
+	return keyIdent(key, b.Namespace, b.SetName)
+}
+
+func (b MCBatcher) ValueIdent(val any) any {
+	return valueIdent(val)
+}
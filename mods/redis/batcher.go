@@ -11,6 +11,14 @@ type Batcher struct {
 	Client *redis.Client
 }
 
+// Ping checks availability of Redis server.
+func (b Batcher) Ping(ctx context.Context) error {
+	if b.Client == nil {
+		return ErrNoClient
+	}
+	return b.Client.WithContext(ctx).Ping().Err()
+}
+
 func (b Batcher) Batch(dst []any, keys []any, _ context.Context) ([]any, error) {
 	if b.Client == nil {
 		return dst, ErrNoClient
@@ -38,6 +46,47 @@ func (b Batcher) Batch(dst []any, keys []any, _ context.Context) ([]any, error)
 	return dst, nil
 }
 
+// BatchOrdered processes collected batch and returns values aligned with keys.
+// Missing keys and keys of unsupported types have nil values.
+func (b Batcher) BatchOrdered(dst []any, keys []any, _ context.Context) ([]any, error) {
+	if b.Client == nil {
+		return dst, ErrNoClient
+	}
+
+	off := len(dst)
+	skeys := make([]string, 0, len(keys))
+	pos := make([]int, 0, len(keys))
+	for i := 0; i < len(keys); i++ {
+		dst = append(dst, nil)
+		switch x := keys[i].(type) {
+		case string:
+			skeys = append(skeys, x)
+		case []byte:
+			skeys = append(skeys, byteconv.B2S(x))
+		default:
+			continue
+		}
+		pos = append(pos, off+i)
+	}
+	if len(skeys) == 0 {
+		return dst, nil
+	}
+	vals, err := b.Client.MGet(skeys...).Result()
+	if err != nil {
+		return dst[:off], err
+	}
+	for i := 0; i < len(vals) && i < len(pos); i++ {
+		if vals[i] == nil {
+			continue
+		}
+		dst[pos[i]] = Tuple{
+			Key:   skeys[i],
+			Value: vals[i],
+		}
+	}
+	return dst, nil
+}
+
 func (b Batcher) MatchKey(key, val any) bool {
 	var skey string
 	switch x := key.(type) {
@@ -55,3 +104,25 @@ func (b Batcher) MatchKey(key, val any) bool {
 	}
 	return false
 }
+
+func (b Batcher) KeyIdent(key any) any {
+	switch x := key.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	}
+	return nil
+}
+
+func (b Batcher) ValueIdent(val any) any {
+	switch x := val.(type) {
+	case Tuple:
+		return x.Key
+	case *Tuple:
+		if x != nil {
+			return x.Key
+		}
+	}
+	return nil
+}
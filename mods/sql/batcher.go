@@ -13,6 +13,14 @@ type Batcher struct {
 	RecordMatcher  RecordMatcher
 }
 
+// Ping checks availability of the database.
+func (b Batcher) Ping(ctx context.Context) error {
+	if b.DB == nil {
+		return ErrNoDB
+	}
+	return b.DB.PingContext(ctx)
+}
+
 func (b Batcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, error) {
 	if b.DB == nil {
 		return dst, ErrNoDB
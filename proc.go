@@ -0,0 +1,11 @@
+package batch_query
+
+import _ "unsafe" // go:linkname
+
+// Pin current goroutine to its P and return P's id.
+//
+//go:linkname procPin runtime.procPin
+func procPin() int
+
+//go:linkname procUnpin runtime.procUnpin
+func procUnpin()
@@ -0,0 +1,92 @@
+package batch_query
+
+import "sync/atomic"
+
+// Reconfigure applies new config to the active query on the fly.
+//
+// New config checks the same way as in New. Collected and processing batches aren't affected, new params apply to
+// the next batches. Following params can't be changed on the fly and must be equal to the current ones: Shards,
+// Buffer, Batcher, Clock, Cache, NegativeCache, Breaker and presence of Adaptive. Otherwise, ErrNotLive returns and query
+// keeps the current config.
+func (q *BatchQuery) Reconfigure(conf *Config) error {
+	if conf == nil {
+		return ErrNoConfig
+	}
+	q.once.Do(q.init)
+	switch q.getStatus() {
+	case StatusNil:
+		return ErrQueryNil
+	case StatusFail, StatusClose:
+		return ErrQueryClosed
+	}
+
+	c := conf.Copy()
+	if err := c.prepare(); err != nil {
+		return err
+	}
+
+	q.rmux.Lock()
+	defer q.rmux.Unlock()
+	if s := q.getStatus(); s == StatusClose || s == StatusFail {
+		// Query has been closed concurrently.
+		return ErrQueryClosed
+	}
+	cur := q.cfg()
+	if err := cur.liveCompatible(c); err != nil {
+		return err
+	}
+	// Components that can't be changed keep their configs.
+	c.Cache, c.NegativeCache, c.Breaker = cur.Cache, cur.NegativeCache, cur.Breaker
+
+	bsize, cival := c.BatchSize, c.CollectInterval
+	if c.Adaptive != nil {
+		if bsize == cur.BatchSize && cival == cur.CollectInterval {
+			// Initial values aren't changed, so keep adjusted values within new bounds.
+			bsize, cival = q.batchSize(), q.collectInterval()
+		}
+		bsize, cival = c.Adaptive.clamp(bsize, cival)
+		q.adaptive.setConf(c.Adaptive)
+	}
+	q.live.Store(c)
+	atomic.StoreUint64(&q.bsize, bsize)
+	atomic.StoreInt64(&q.cival, int64(cival))
+
+	// Flush buffers if they already exceed new batch size.
+	for _, s := range q.shards {
+		s.mux.Lock()
+		if q.getStatus() != StatusClose && uint64(len(s.buf)) >= bsize {
+			s.timer.pause()
+			q.flushLF(s, flushReasonSize)
+		}
+		s.mux.Unlock()
+	}
+
+	// Fit workers count to the new settings. Workers that are going to stop aren't counted.
+	var n uint32
+	if w, r := atomic.LoadUint32(&q.workers), atomic.LoadUint32(&q.retiring); w > r {
+		n = w - r
+	}
+	target := uint32(c.Workers)
+	if c.MaxWorkers > 0 {
+		target = n
+		if min := uint32(c.MinWorkers); target < min {
+			target = min
+		}
+		if max := uint32(c.MaxWorkers); target > max {
+			target = max
+		}
+	}
+	if n < target {
+		// Cancel pending retirements first.
+		for i := n + q.unretire(target-n); i < target; i++ {
+			q.spawn()
+		}
+	} else if n > target {
+		q.retire(n - target)
+	}
+
+	if l := q.l(); l != nil {
+		l.Printf("reconfigured: batch size %d, collect interval %s, %d workers\n", bsize, cival, target)
+	}
+	return nil
+}
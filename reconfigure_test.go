@@ -0,0 +1,157 @@
+package batch_query
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Metrics writer that counts started workers.
+type workerMetrics struct {
+	DummyMetrics
+	up int32
+}
+
+func (m *workerMetrics) WorkerUp() {
+	atomic.AddInt32(&m.up, 1)
+}
+
+type matcher interface {
+	Match(key, val any) bool
+}
+
+type matchFunc func(key, val any) bool
+
+func (f matchFunc) Match(key, val any) bool {
+	return f(key, val)
+}
+
+// Batcher passed by value, comparable by type but may hold uncomparable matcher.
+type valueBatcher struct {
+	m matcher
+}
+
+func (b valueBatcher) Batch(dst []any, keys []any, _ context.Context) ([]any, error) {
+	for i := 0; i < len(keys); i++ {
+		dst = append(dst, keys[i].(int)*2)
+	}
+	return dst, nil
+}
+
+func (b valueBatcher) MatchKey(key, val any) bool {
+	return b.m.Match(key, val)
+}
+
+func TestReconfigure(t *testing.T) {
+	conf := Config{BatchSize: 100, CollectInterval: time.Second, TimeoutInterval: time.Second, Workers: 1,
+		Batcher: &testBatcher{}}
+	t.Run("live", func(t *testing.T) {
+		q := newTestQuery(t, &conf)
+		c := conf
+		c.BatchSize, c.Workers = 2, 3
+		if err := q.Reconfigure(&c); err != nil {
+			t.Fatal(err)
+		}
+		// Batch flushes by new size without waiting the collect interval.
+		start := time.Now()
+		if _, errs := q.FetchMany([]any{1, 2}); errs[0] != nil || errs[1] != nil {
+			t.Fatal(errs)
+		}
+		if time.Since(start) >= time.Second {
+			t.Fatal("batch size wasn't applied")
+		}
+		if n := q.Stats().Workers; n != 3 {
+			t.Fatalf("unexpected workers count %d", n)
+		}
+	})
+	t.Run("workers", func(t *testing.T) {
+		// Shrinking is asynchronous, so the next reconfiguration mustn't rely on the running workers count.
+		c := conf
+		c.Workers = 4
+		q := newTestQuery(t, &c)
+		for _, n := range []uint{1, 4, 2, 3} {
+			c.Workers = n
+			if err := q.Reconfigure(&c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		waitFor(t, func() bool { return q.Stats().Workers == 3 })
+		time.Sleep(20 * time.Millisecond)
+		if n := q.Stats().Workers; n != 3 {
+			t.Fatalf("unexpected workers count %d", n)
+		}
+	})
+	t.Run("not live", func(t *testing.T) {
+		q := newTestQuery(t, &conf)
+		for _, fn := range []func(c *Config){
+			func(c *Config) { c.Buffer = 10 },
+			func(c *Config) { c.Shards = 4 },
+			func(c *Config) { c.Batcher = &testBatcher{} },
+			func(c *Config) { c.Clock = NewFakeClock(time.Now()) },
+			func(c *Config) { c.Cache = &CacheConfig{} },
+		} {
+			c := conf
+			fn(&c)
+			if err := q.Reconfigure(&c); !errors.Is(err, ErrNotLive) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+	})
+	t.Run("value batcher", func(t *testing.T) {
+		c := conf
+		c.Batcher = valueBatcher{m: matchFunc(func(key, val any) bool { return key.(int)*2 == val.(int) })}
+		q := newTestQuery(t, &c)
+		c.BatchSize = 2
+		if err := q.Reconfigure(&c); err != nil {
+			t.Fatal(err)
+		}
+		c.Batcher = valueBatcher{m: matchFunc(func(key, val any) bool { return false })}
+		if err := q.Reconfigure(&c); !errors.Is(err, ErrNotLive) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("bad config", func(t *testing.T) {
+		q := newTestQuery(t, &conf)
+		c := conf
+		c.CollectInterval = time.Minute
+		if err := q.Reconfigure(&c); err != ErrBadIntervals {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if q.cfg().CollectInterval != time.Second {
+			t.Fatal("bad config was applied")
+		}
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		// Concurrent reconfiguration mustn't spawn workers after close.
+		for i := 0; i < 100; i++ {
+			mw := &workerMetrics{}
+			c := conf
+			c.MetricsWriter = mw
+			q := newTestQuery(t, &c)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c := c
+				for w := uint(2); ; w++ {
+					c.Workers = w
+					if err := q.Reconfigure(&c); err != nil {
+						if err != ErrQueryClosed {
+							t.Error(err)
+						}
+						return
+					}
+				}
+			}()
+			if err := q.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			up := atomic.LoadInt32(&mw.up)
+			<-done
+			if n := atomic.LoadInt32(&mw.up); n != up {
+				t.Fatalf("%d workers started after shutdown", n-up)
+			}
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package batch_query
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how to retry failed batches.
+type RetryPolicy interface {
+	// Backoff returns delay before given retry attempt (starts from 1) of the batch failed due to err.
+	// Returns false if batch shouldn't be retried anymore.
+	Backoff(attempt uint, err error) (time.Duration, bool)
+}
+
+// ExponentialRetry is a built-in retry policy with exponential backoff and jitter.
+type ExponentialRetry struct {
+	// Max attempts count including the first one.
+	MaxAttempts uint
+	// Delay before the first retry. Each next retry doubles the delay.
+	BaseDelay time.Duration
+	// Max delay between retries.
+	// Zero value means no limit.
+	MaxDelay time.Duration
+	// Random deviation of the delay in range [0..1], eg 0.2 means +/-20% of the delay.
+	Jitter float64
+	// Retryable checks if error is transient and batch may be retried.
+	// If this param omit all errors are retryable.
+	Retryable func(err error) bool
+}
+
+func (r ExponentialRetry) Backoff(attempt uint, err error) (time.Duration, bool) {
+	if attempt >= r.MaxAttempts {
+		return 0, false
+	}
+	if r.Retryable != nil && !r.Retryable(err) {
+		return 0, false
+	}
+	delay := r.BaseDelay
+	for i := uint(1); i < attempt && delay > 0; i++ {
+		if delay > math.MaxInt64/2 {
+			// Saturate instead of overflow.
+			delay = math.MaxInt64
+			break
+		}
+		if delay *= 2; r.MaxDelay > 0 && delay > r.MaxDelay {
+			break
+		}
+	}
+	if r.MaxDelay > 0 && delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	if r.Jitter > 0 && delay > 0 {
+		d := float64(delay) * (1 + (rand.Float64()*2-1)*r.Jitter)
+		if d >= math.MaxInt64 {
+			delay = math.MaxInt64
+		} else {
+			delay = time.Duration(d)
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// Exec batch operation and retry it according retry policy.
+// Retries stop if delay exceeds the latest deadline of batch requests.
+func (q *BatchQuery) batchRetry(keys, ids []any, deadline time.Time, idx uint64, ctx context.Context) (res []Result, err error) {
+	res, err = q.batch(keys, ids, ctx)
+	rp := q.cfg().RetryPolicy
+	if rp == nil {
+		return
+	}
+	for attempt := uint(1); err != nil; attempt++ {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		delay, ok := rp.Backoff(attempt, err)
+		if !ok {
+			return
+		}
+		if !deadline.IsZero() && q.now().Add(delay).After(deadline) {
+			if l := q.l(); l != nil {
+				l.Printf("batch #%d retry #%d cancelled due to deadline\n", idx, attempt)
+			}
+			return
+		}
+		if delay > 0 {
+			t := q.clock().NewTimer(delay)
+			select {
+			case <-t.C():
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+		if l := q.l(); l != nil {
+			l.Printf("batch #%d retry #%d after error: %s\n", idx, attempt, err.Error())
+		}
+		q.mw().BatchRetry()
+		res, err = q.batch(keys, ids, ctx)
+	}
+	return
+}
@@ -0,0 +1,70 @@
+package batch_query
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExponentialRetry(t *testing.T) {
+	stages := []struct {
+		policy  ExponentialRetry
+		attempt uint
+		delay   time.Duration
+		ok      bool
+	}{
+		{ExponentialRetry{MaxAttempts: 5, BaseDelay: time.Millisecond}, 1, time.Millisecond, true},
+		{ExponentialRetry{MaxAttempts: 5, BaseDelay: time.Millisecond}, 3, 4 * time.Millisecond, true},
+		{ExponentialRetry{MaxAttempts: 5, BaseDelay: time.Millisecond}, 5, 0, false},
+		{ExponentialRetry{MaxAttempts: 10, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}, 8, 5 * time.Millisecond, true},
+		{ExponentialRetry{MaxAttempts: 5, BaseDelay: time.Millisecond, Retryable: func(error) bool { return false }}, 1, 0, false},
+		// Doubling saturates instead of overflow.
+		{ExponentialRetry{MaxAttempts: 1000, BaseDelay: time.Millisecond}, 50, math.MaxInt64, true},
+		{ExponentialRetry{MaxAttempts: 1000, BaseDelay: time.Millisecond}, 999, math.MaxInt64, true},
+		{ExponentialRetry{MaxAttempts: 1000, BaseDelay: time.Millisecond, MaxDelay: time.Second}, 999, time.Second, true},
+	}
+	for i, stage := range stages {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			delay, ok := stage.policy.Backoff(stage.attempt, errTest)
+			if delay != stage.delay || ok != stage.ok {
+				t.Fatalf("unexpected backoff %s %v", delay, ok)
+			}
+		})
+	}
+	t.Run("jitter", func(t *testing.T) {
+		r := ExponentialRetry{MaxAttempts: 1000, BaseDelay: time.Second, Jitter: .5}
+		for i := 0; i < 100; i++ {
+			if delay, _ := r.Backoff(1, errTest); delay < time.Second/2 || delay > 3*time.Second/2 {
+				t.Fatalf("delay %s out of jitter bounds", delay)
+			}
+			if delay, _ := r.Backoff(100, errTest); delay <= 0 {
+				t.Fatalf("delay %s overflows", delay)
+			}
+		}
+	})
+}
+
+func TestBatchRetry(t *testing.T) {
+	var fails int32 = 2
+	b := &testBatcher{fail: func([]any) error {
+		if atomic.AddInt32(&fails, -1) >= 0 {
+			return errTest
+		}
+		return nil
+	}}
+	q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Second, Workers: 1, Batcher: b,
+		RetryPolicy: ExponentialRetry{MaxAttempts: 3}})
+	if val, err := q.Fetch(1); err != nil || val != 2 {
+		t.Fatalf("unexpected result %v %v", val, err)
+	}
+	if n := q.Stats().BatchRetry; n != 2 {
+		t.Fatalf("unexpected retries count %d", n)
+	}
+	// Retries are over.
+	atomic.StoreInt32(&fails, 3)
+	if _, err := q.Fetch(2); err != errTest {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package batch_query
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardBy describes how requests distribute among collecting shards.
+type ShardBy uint8
+
+const (
+	// ShardByKey selects shard by hash of key identity, so requests of the same key go to the same shard and may be
+	// deduplicated. Keys without hashable identity distribute among shards round-robin.
+	ShardByKey ShardBy = iota
+	// ShardByProc selects shard by logical processor (P) of the caller goroutine. Gives minimal contention, but
+	// requests of the same key may go to different shards.
+	ShardByProc
+)
+
+// Independent collecting buffer with own lock and timer.
+type shard struct {
+	mux   sync.Mutex
+	buf   []pair
+	timer *timer
+	// Closed shard doesn't accept requests anymore.
+	closed bool
+}
+
+func newShard(clock Clock) *shard {
+	s := shard{timer: newTimer(clock)}
+	return &s
+}
+
+// Stop timers and lock all shards.
+func (q *BatchQuery) lockShards() {
+	for _, s := range q.shards {
+		s.timer.stop()
+		s.mux.Lock()
+	}
+}
+
+func (q *BatchQuery) unlockShards() {
+	for _, s := range q.shards {
+		s.mux.Unlock()
+	}
+}
+
+// Get shard to collect request with given key identity.
+func (q *BatchQuery) shardOf(id any) *shard {
+	n := uint64(len(q.shards))
+	if n == 1 {
+		return q.shards[0]
+	}
+	var h uint64
+	switch q.cfg().ShardBy {
+	case ShardByProc:
+		h = uint64(procPin())
+		procUnpin()
+	default:
+		var ok bool
+		if h, ok = hashOf(id); !ok {
+			h = atomic.AddUint64(&q.rr, 1)
+		}
+	}
+	return q.shards[h%n]
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// Calculate hash of key identity.
+// Returns false if identity type isn't supported.
+func hashOf(id any) (uint64, bool) {
+	switch x := id.(type) {
+	case nil:
+		return 0, false
+	case string:
+		return hashStr(fnvOffset, x), true
+	case int:
+		return mix(uint64(x)), true
+	case int32:
+		return mix(uint64(x)), true
+	case int64:
+		return mix(uint64(x)), true
+	case uint:
+		return mix(uint64(x)), true
+	case uint32:
+		return mix(uint64(x)), true
+	case uint64:
+		return mix(x), true
+	default:
+		// Other hashable identities (eg. fixed arrays or structs) hash by their fields.
+		h, ok := hashValue(fnvOffset, reflect.ValueOf(id))
+		return mix(h), ok
+	}
+}
+
+// FNV-1a hash of the string.
+func hashStr(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return h
+}
+
+// Add value to FNV-1a hash h word by word.
+func hashValue(h uint64, v reflect.Value) (uint64, bool) {
+	word := func(x uint64) uint64 {
+		return (h ^ x) * fnvPrime
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return word(1), true
+		}
+		return word(0), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return word(uint64(v.Int())), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return word(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			// Equal +0 and -0 must have the same hash.
+			f = 0
+		}
+		return word(math.Float64bits(f)), true
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		h, _ = hashValue(h, reflect.ValueOf(real(c)))
+		return hashValue(h, reflect.ValueOf(imag(c)))
+	case reflect.String:
+		return hashStr(h, v.String()), true
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return word(uint64(v.Pointer())), true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var ok bool
+			if h, ok = hashValue(h, v.Index(i)); !ok {
+				return 0, false
+			}
+		}
+		return h, true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			var ok bool
+			if h, ok = hashValue(h, v.Field(i)); !ok {
+				return 0, false
+			}
+		}
+		return h, true
+	default:
+		return 0, false
+	}
+}
+
+// Finalizer of splitmix64, spreads sequential integers among shards.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,91 @@
+package batch_query
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type userID int
+
+type pairKey struct {
+	A string
+	B [2]int64
+	C float64
+}
+
+func TestHashOf(t *testing.T) {
+	stages := []struct {
+		a, b any
+	}{
+		{a: "foo", b: "foo"},
+		{a: 42, b: 42},
+		{a: userID(42), b: userID(42)},
+		{a: [20]byte{1, 2, 3}, b: [20]byte{1, 2, 3}},
+		{a: pairKey{A: "x", B: [2]int64{1, 2}, C: 0}, b: pairKey{A: "x", B: [2]int64{1, 2}, C: -1 * 0.0}},
+		{a: complex(1, 2), b: complex(1, 2)},
+	}
+	for i, stg := range stages {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			ha, ok := hashOf(stg.a)
+			if !ok {
+				t.Fatalf("%T isn't hashed", stg.a)
+			}
+			hb, _ := hashOf(stg.b)
+			if ha != hb {
+				t.Fatal("equal identities have different hashes")
+			}
+		})
+	}
+	t.Run("distinct", func(t *testing.T) {
+		a, _ := hashOf([20]byte{1})
+		b, _ := hashOf([20]byte{2})
+		if a == b {
+			t.Fatal("different identities have the same hash")
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		for _, id := range []any{nil, ifaceKey{X: 1}, []int{1}} {
+			if _, ok := hashOf(id); ok {
+				t.Fatalf("%T mustn't be hashed", id)
+			}
+		}
+	})
+}
+
+func TestShards(t *testing.T) {
+	t.Run("by key", func(t *testing.T) {
+		// Requests of the same key go to the same shard and deduplicate.
+		b := &testBatcher{}
+		q := newTestQuery(t, &Config{BatchSize: 100, CollectInterval: 10 * time.Millisecond,
+			TimeoutInterval: time.Second, Workers: 2, Shards: 4, Batcher: b})
+		keys := []any{1, 2, 3, 4, 5, 1, 2, 3, 4, 5}
+		vals, errs := q.FetchMany(keys)
+		for i := 0; i < len(keys); i++ {
+			if vals[i] != keys[i].(int)*2 || errs[i] != nil {
+				t.Fatalf("unexpected result %v %v", vals[i], errs[i])
+			}
+		}
+		if n := q.Stats().Dedup; n != 5 {
+			t.Fatalf("unexpected dedup count %d", n)
+		}
+		var n int
+		for _, c := range b.calls() {
+			n += len(c)
+		}
+		if n != 5 {
+			t.Fatalf("unexpected batched keys count %d", n)
+		}
+	})
+	t.Run("by proc", func(t *testing.T) {
+		q := newTestQuery(t, &Config{BatchSize: 100, CollectInterval: 10 * time.Millisecond,
+			TimeoutInterval: time.Second, Workers: 2, Shards: 4, ShardBy: ShardByProc, Batcher: &testBatcher{}})
+		keys := []any{1, 2, 3}
+		vals, errs := q.FetchMany(keys)
+		for i := 0; i < len(keys); i++ {
+			if vals[i] != keys[i].(int)*2 || errs[i] != nil {
+				t.Fatalf("unexpected result %v %v", vals[i], errs[i])
+			}
+		}
+	})
+}
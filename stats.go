@@ -0,0 +1,375 @@
+package batch_query
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Count of sub-buckets per power of two in latency histogram is 1<<histBits.
+	histBits = 2
+	histSub  = 1 << histBits
+	histSize = 64 * histSub
+)
+
+// Stats represents snapshot of query counters and gauges.
+// See BatchQuery.Stats.
+type Stats struct {
+	// Requests waiting for the response.
+	Pending uint64
+	// Batches waiting in the buffer.
+	Buffered int
+	// Running and busy workers.
+	Workers     uint32
+	BusyWorkers uint32
+
+	// Totals of requests per outcome.
+	Fetch     uint64
+	OK        uint64
+	NotFound  uint64
+	Timeout   uint64
+	Interrupt uint64
+	Fail      uint64
+	Throttle  uint64
+	// Totals of requests collapsed by deduplication, pruned due to cancellation and answered from the cache.
+	Dedup    uint64
+	Prune    uint64
+	CacheHit uint64
+
+	// Totals of batches per outcome.
+	Batch      uint64
+	BatchOK    uint64
+	BatchFail  uint64
+	BatchRetry uint64
+	Isolate    uint64
+	// Totals of hedged batch requests fired and won.
+	Hedge    uint64
+	HedgeWin uint64
+	// Totals of flushes per reason.
+	Flush FlushStats
+	// Average ratio of batch size to max batch size.
+	FillRatio float64
+
+	// Latency of successful requests and batches.
+	Latency      LatencyStats
+	BatchLatency LatencyStats
+}
+
+// FlushStats represents totals of flushes per reason.
+type FlushStats struct {
+	Size     uint64
+	Interval uint64
+	Force    uint64
+	Deadline uint64
+}
+
+// LatencyStats represents latency quantiles.
+// Quantiles are estimated using log-scale histogram with relative error about 12%.
+type LatencyStats struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Internal stats collector.
+// Works as a decorator of configured metrics writer, so all metrics hooks are counted.
+type stats struct {
+	q *BatchQuery
+
+	fetch, ok, notFound, timeout, interrupt, fail, throttle uint64
+	dedup, prune, cacheHit                                  uint64
+	batch, batchOK, batchFail, batchRetry, isolate          uint64
+	hedge, hedgeWin                                         uint64
+
+	flush [4]uint64
+	// Sum of batch sizes and max batch sizes, uses to calculate fill ratio.
+	filled, capacity uint64
+
+	lat, blat histogram
+}
+
+// Stats returns snapshot of query counters and gauges.
+// Snapshot takes using atomic loads without locks, so counters may be slightly inconsistent with each other.
+func (q *BatchQuery) Stats() Stats {
+	q.once.Do(q.init)
+	s := &q.stats
+	st := Stats{
+		Workers:     atomic.LoadUint32(&q.workers),
+		BusyWorkers: atomic.LoadUint32(&q.busy),
+
+		Fetch:     atomic.LoadUint64(&s.fetch),
+		OK:        atomic.LoadUint64(&s.ok),
+		NotFound:  atomic.LoadUint64(&s.notFound),
+		Timeout:   atomic.LoadUint64(&s.timeout),
+		Interrupt: atomic.LoadUint64(&s.interrupt),
+		Fail:      atomic.LoadUint64(&s.fail),
+		Throttle:  atomic.LoadUint64(&s.throttle),
+		Dedup:     atomic.LoadUint64(&s.dedup),
+		Prune:     atomic.LoadUint64(&s.prune),
+		CacheHit:  atomic.LoadUint64(&s.cacheHit),
+
+		Batch:      atomic.LoadUint64(&s.batch),
+		BatchOK:    atomic.LoadUint64(&s.batchOK),
+		BatchFail:  atomic.LoadUint64(&s.batchFail),
+		BatchRetry: atomic.LoadUint64(&s.batchRetry),
+		Isolate:    atomic.LoadUint64(&s.isolate),
+		Hedge:      atomic.LoadUint64(&s.hedge),
+		HedgeWin:   atomic.LoadUint64(&s.hedgeWin),
+		Flush: FlushStats{
+			Size:     atomic.LoadUint64(&s.flush[flushReasonSize]),
+			Interval: atomic.LoadUint64(&s.flush[flushReasonInterval]),
+			Force:    atomic.LoadUint64(&s.flush[flushReasonForce]),
+			Deadline: atomic.LoadUint64(&s.flush[flushReasonDeadline]),
+		},
+
+		Latency:      s.lat.stats(),
+		BatchLatency: s.blat.stats(),
+	}
+	if q.c != nil {
+		st.Buffered = len(q.c)
+	}
+	if done := st.OK + st.NotFound + st.Timeout + st.Interrupt + st.Fail; st.Fetch > done {
+		st.Pending = st.Fetch - done
+	}
+	if c := atomic.LoadUint64(&s.capacity); c > 0 {
+		st.FillRatio = float64(atomic.LoadUint64(&s.filled)) / float64(c)
+	}
+	return st
+}
+
+// Register flushed batch of given size.
+func (s *stats) flushed(reason flushReason, size, max uint64) {
+	if int(reason) < len(s.flush) {
+		atomic.AddUint64(&s.flush[reason], 1)
+	}
+	if size > max {
+		size = max
+	}
+	atomic.AddUint64(&s.filled, size)
+	atomic.AddUint64(&s.capacity, max)
+}
+
+// Get underlying metrics writer.
+func (s *stats) w() MetricsWriter {
+	if s.q != nil {
+		if c := s.q.cfg(); c != nil && c.MetricsWriter != nil {
+			return c.MetricsWriter
+		}
+	}
+	return DummyMetrics{}
+}
+
+// Get underlying metrics writer of extended metrics.
+func (s *stats) x() MetricsWriterExt {
+	if x, ok := s.w().(MetricsWriterExt); ok {
+		return x
+	}
+	return DummyMetrics{}
+}
+
+func (s *stats) Fetch() {
+	atomic.AddUint64(&s.fetch, 1)
+	s.w().Fetch()
+}
+
+func (s *stats) OK(duration time.Duration) {
+	atomic.AddUint64(&s.ok, 1)
+	s.lat.observe(duration)
+	s.w().OK(duration)
+}
+
+func (s *stats) NotFound() {
+	atomic.AddUint64(&s.notFound, 1)
+	s.w().NotFound()
+}
+
+func (s *stats) Timeout() {
+	atomic.AddUint64(&s.timeout, 1)
+	s.w().Timeout()
+}
+
+func (s *stats) Interrupt() {
+	atomic.AddUint64(&s.interrupt, 1)
+	s.w().Interrupt()
+}
+
+func (s *stats) Fail() {
+	atomic.AddUint64(&s.fail, 1)
+	s.w().Fail()
+}
+
+func (s *stats) Throttle() {
+	atomic.AddUint64(&s.throttle, 1)
+	s.x().Throttle()
+}
+
+func (s *stats) Batch() {
+	atomic.AddUint64(&s.batch, 1)
+	s.w().Batch()
+}
+
+func (s *stats) BatchOK(duration time.Duration) {
+	atomic.AddUint64(&s.batchOK, 1)
+	s.blat.observe(duration)
+	s.w().BatchOK(duration)
+}
+
+func (s *stats) BatchFail() {
+	atomic.AddUint64(&s.batchFail, 1)
+	s.w().BatchFail()
+}
+
+func (s *stats) BatchRetry() {
+	atomic.AddUint64(&s.batchRetry, 1)
+	s.x().BatchRetry()
+}
+
+func (s *stats) Isolate() {
+	atomic.AddUint64(&s.isolate, 1)
+	s.x().Isolate()
+}
+
+func (s *stats) Hedge() {
+	atomic.AddUint64(&s.hedge, 1)
+	s.x().Hedge()
+}
+
+func (s *stats) HedgeWin() {
+	atomic.AddUint64(&s.hedgeWin, 1)
+	s.x().HedgeWin()
+}
+
+func (s *stats) BufferIn(reason string) {
+	s.w().BufferIn(reason)
+}
+
+func (s *stats) BufferOut() {
+	s.w().BufferOut()
+}
+
+func (s *stats) WorkerUp() {
+	s.x().WorkerUp()
+}
+
+func (s *stats) WorkerDown() {
+	s.x().WorkerDown()
+}
+
+func (s *stats) Dedup(count int) {
+	atomic.AddUint64(&s.dedup, uint64(count))
+	s.x().Dedup(count)
+}
+
+func (s *stats) Prune(count int) {
+	atomic.AddUint64(&s.prune, uint64(count))
+	s.x().Prune(count)
+}
+
+func (s *stats) CacheHit() {
+	atomic.AddUint64(&s.cacheHit, 1)
+	s.x().CacheHit()
+}
+
+func (s *stats) CacheMiss() {
+	s.x().CacheMiss()
+}
+
+func (s *stats) CacheEvict(count int) {
+	s.x().CacheEvict(count)
+}
+
+func (s *stats) Adapt(batchSize uint64, collectInterval time.Duration) {
+	s.x().Adapt(batchSize, collectInterval)
+}
+
+func (s *stats) BreakerState(state string) {
+	s.x().BreakerState(state)
+}
+
+// Lock-free log-scale histogram of durations.
+type histogram struct {
+	b   [histSize]uint64
+	max int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	atomic.AddUint64(&h.b[histIndex(uint64(d))], 1)
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&h.max, max, int64(d)) {
+			return
+		}
+	}
+}
+
+func (h *histogram) stats() LatencyStats {
+	var buf [histSize]uint64
+	total, max := h.load(&buf)
+	return LatencyStats{
+		P50: quantile(&buf, total, .5, max),
+		P90: quantile(&buf, total, .9, max),
+		P99: quantile(&buf, total, .99, max),
+		Max: max,
+	}
+}
+
+// Estimate quantile q. Also returns count of observed values.
+func (h *histogram) quantile(q float64) (time.Duration, uint64) {
+	var buf [histSize]uint64
+	total, max := h.load(&buf)
+	return quantile(&buf, total, q, max), total
+}
+
+// Load buckets to buf. Returns total count and max observed value.
+func (h *histogram) load(buf *[histSize]uint64) (total uint64, max time.Duration) {
+	for i := 0; i < histSize; i++ {
+		buf[i] = atomic.LoadUint64(&h.b[i])
+		total += buf[i]
+	}
+	max = time.Duration(atomic.LoadInt64(&h.max))
+	return
+}
+
+// Get bucket index of the value.
+// Values less than 2*histSub have own buckets, greater values split each power of two to histSub buckets.
+func histIndex(v uint64) int {
+	if v < 2*histSub {
+		return int(v)
+	}
+	e := bits.Len64(v) - 1
+	m := (v >> (e - histBits)) & (histSub - 1)
+	return (e-1)*histSub + int(m)
+}
+
+// Get middle value of the bucket.
+func histValue(i int) uint64 {
+	if i < 2*histSub {
+		return uint64(i)
+	}
+	e, m := i/histSub+1, uint64(i%histSub)
+	lo := (histSub + m) << (e - histBits)
+	return lo + (uint64(1)<<(e-histBits))/2
+}
+
+// Estimate quantile q, that can't exceed max observed value.
+func quantile(buf *[histSize]uint64, total uint64, q float64, max time.Duration) time.Duration {
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(total-1)) + 1
+	var c uint64
+	for i := 0; i < histSize; i++ {
+		if c += buf[i]; c >= rank {
+			if v := time.Duration(histValue(i)); v < max {
+				return v
+			}
+			return max
+		}
+	}
+	return max
+}
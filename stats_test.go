@@ -0,0 +1,80 @@
+package batch_query
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Metrics writer that implements base hooks only and counts requests.
+type baseMetrics struct {
+	fetch uint32
+}
+
+func (m *baseMetrics) Fetch()                  { atomic.AddUint32(&m.fetch, 1) }
+func (m *baseMetrics) OK(_ time.Duration)      {}
+func (m *baseMetrics) NotFound()               {}
+func (m *baseMetrics) Timeout()                {}
+func (m *baseMetrics) Interrupt()              {}
+func (m *baseMetrics) Fail()                   {}
+func (m *baseMetrics) Batch()                  {}
+func (m *baseMetrics) BatchOK(_ time.Duration) {}
+func (m *baseMetrics) BatchFail()              {}
+func (m *baseMetrics) BufferIn(_ string)       {}
+func (m *baseMetrics) BufferOut()              {}
+
+func TestHistogram(t *testing.T) {
+	var h histogram
+	if st := h.stats(); st != (LatencyStats{}) {
+		t.Fatalf("unexpected stats of empty histogram %v", st)
+	}
+	for i := 1; i <= 1000; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	st := h.stats()
+	stages := []struct {
+		q, want time.Duration
+	}{
+		{q: st.P50, want: 500 * time.Millisecond},
+		{q: st.P90, want: 900 * time.Millisecond},
+		{q: st.P99, want: 990 * time.Millisecond},
+	}
+	for i, stg := range stages {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if d := float64(stg.q-stg.want) / float64(stg.want); d < -.125 || d > .125 {
+				t.Fatalf("quantile %s too far from %s", stg.q, stg.want)
+			}
+		})
+	}
+	if st.Max != time.Second {
+		t.Fatalf("unexpected max %s", st.Max)
+	}
+	if st.P99 > st.Max {
+		t.Fatal("quantile exceeds max")
+	}
+}
+
+func TestStats(t *testing.T) {
+	mw := &baseMetrics{}
+	q := newTestQuery(t, &Config{BatchSize: 4, CollectInterval: time.Second, TimeoutInterval: 2 * time.Second,
+		Workers: 1, Batcher: &testBatcher{}, MetricsWriter: mw})
+	q.FetchMany([]any{1, 2, 10, 1})
+	st := q.Stats()
+	if st.Fetch != 4 || st.OK != 3 || st.NotFound != 1 || st.Pending != 0 {
+		t.Fatalf("unexpected requests stats %+v", st)
+	}
+	if st.Batch != 1 || st.BatchOK != 1 || st.Flush.Size != 1 || st.FillRatio != 1 {
+		t.Fatalf("unexpected batches stats %+v", st)
+	}
+	// Extended hooks count even if metrics writer doesn't implement them.
+	if st.Dedup != 1 {
+		t.Fatalf("unexpected dedup count %d", st.Dedup)
+	}
+	if st.Latency.Max <= 0 || st.BatchLatency.Max <= 0 {
+		t.Fatalf("latency isn't observed %+v", st)
+	}
+	if n := atomic.LoadUint32(&mw.fetch); n != 4 {
+		t.Fatalf("metrics writer got %d requests", n)
+	}
+}
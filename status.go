@@ -0,0 +1,92 @@
+package batch_query
+
+import "context"
+
+func (s Status) String() string {
+	switch s {
+	case StatusNil:
+		return "nil"
+	case StatusFail:
+		return "fail"
+	case StatusActive:
+		return "active"
+	case StatusThrottle:
+		return "throttle"
+	case StatusClose:
+		return "close"
+	default:
+		return "unknown"
+	}
+}
+
+// Status returns current status of the query.
+func (q *BatchQuery) Status() Status {
+	q.once.Do(q.init)
+	return q.getStatus()
+}
+
+// Ready checks if query is active and accepts requests.
+// Throttled query isn't ready since most of requests fail fast.
+func (q *BatchQuery) Ready() bool {
+	return q.Status() == StatusActive
+}
+
+// Wait blocks until query becomes ready or ctx done.
+// Returns error if query is failed or closed, since it will never become ready.
+func (q *BatchQuery) Wait(ctx context.Context) error {
+	q.once.Do(q.init)
+	for {
+		// Take notification channel before status check to not miss the change.
+		q.smux.Lock()
+		if q.sc == nil {
+			q.sc = make(chan struct{})
+		}
+		c := q.sc
+		q.smux.Unlock()
+
+		switch q.getStatus() {
+		case StatusActive:
+			return nil
+		case StatusFail:
+			return q.err
+		case StatusClose, StatusNil:
+			return ErrQueryClosed
+		}
+		select {
+		case <-c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Healthy checks if query is active and the storage is available.
+// Storage checks only if batcher implements Pinger interface, ping is limited by timeout interval of the query.
+func (q *BatchQuery) Healthy() error {
+	switch q.Status() {
+	case StatusFail:
+		return q.err
+	case StatusClose, StatusNil:
+		return ErrQueryClosed
+	case StatusThrottle:
+		return ErrThrottled
+	}
+	c := q.cfg()
+	p, ok := c.Batcher.(Pinger)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := q.withTimeout(context.Background(), c.TimeoutInterval)
+	defer cancel()
+	return p.Ping(ctx)
+}
+
+// Wake up goroutines waiting for status change.
+func (q *BatchQuery) notify() {
+	q.smux.Lock()
+	if q.sc != nil {
+		close(q.sc)
+		q.sc = nil
+	}
+	q.smux.Unlock()
+}
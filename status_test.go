@@ -0,0 +1,106 @@
+package batch_query
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Batcher with ping returning given error.
+type errPinger struct {
+	testBatcher
+	err error
+}
+
+func (p *errPinger) Ping(_ context.Context) error {
+	return p.err
+}
+
+func TestStatusString(t *testing.T) {
+	stages := []struct {
+		s    Status
+		want string
+	}{
+		{s: StatusNil, want: "nil"},
+		{s: StatusFail, want: "fail"},
+		{s: StatusActive, want: "active"},
+		{s: StatusThrottle, want: "throttle"},
+		{s: StatusClose, want: "close"},
+		{s: Status(100), want: "unknown"},
+	}
+	for i, stg := range stages {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if s := stg.s.String(); s != stg.want {
+				t.Fatalf("got %q, want %q", s, stg.want)
+			}
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	q := newTestQuery(t, &Config{Workers: 1, Batcher: &testBatcher{}})
+	if !q.Ready() || q.Status() != StatusActive {
+		t.Fatalf("unexpected status %s", q.Status())
+	}
+	if err := q.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	q.casStatus(StatusActive, StatusThrottle)
+	if q.Ready() {
+		t.Fatal("throttled query is ready")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// Wait returns as soon as query becomes active again.
+	done := make(chan error)
+	go func() { done <- q.Wait(context.Background()) }()
+	q.casStatus(StatusThrottle, StatusActive)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Close()
+	if q.Ready() {
+		t.Fatal("closed query is ready")
+	}
+	if err := q.Wait(context.Background()); err != ErrQueryClosed {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	t.Run("no pinger", func(t *testing.T) {
+		q := newTestQuery(t, &Config{Workers: 1, Batcher: &testBatcher{}})
+		if err := q.Healthy(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("ping", func(t *testing.T) {
+		p := &errPinger{}
+		q := newTestQuery(t, &Config{Workers: 1, Batcher: p})
+		if err := q.Healthy(); err != nil {
+			t.Fatal(err)
+		}
+		p.err = errTest
+		if err := q.Healthy(); err != errTest {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("throttle", func(t *testing.T) {
+		q := newTestQuery(t, &Config{Workers: 1, Batcher: &errPinger{}})
+		q.casStatus(StatusActive, StatusThrottle)
+		if err := q.Healthy(); err != ErrThrottled {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+	t.Run("close", func(t *testing.T) {
+		q := newTestQuery(t, &Config{Workers: 1, Batcher: &errPinger{}})
+		_ = q.Close()
+		if err := q.Healthy(); err != ErrQueryClosed {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
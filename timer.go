@@ -9,10 +9,10 @@ import (
 type timerSignal uint8
 
 const (
-	timerSignalReach timerSignal = iota
-	timerSignalPause
+	timerSignalPause timerSignal = iota
 	timerSignalResume
 	timerSignalStop
+	timerSignalDeadline
 )
 
 const (
@@ -22,68 +22,99 @@ const (
 )
 
 // Internal timer implementation.
+// Status changes synchronously, signals just wake up the observer to apply the status to underlying timer. Thus
+// signals may be safely dropped if observer has pending signal.
 type timer struct {
-	t *time.Timer
+	t ClockTimer
 	c chan timerSignal
 	s uint32
+	// Resume generation, uses to restart collecting period on each resume.
+	g uint32
+	// Time of early flush due to deadlines of collected requests (unix nanoseconds), zero means no early flush.
+	d int64
 }
 
-func newTimer() *timer {
+func newTimer(clock Clock) *timer {
 	t := timer{
 		c: make(chan timerSignal, 1),
-		t: time.NewTimer(math.MaxInt64),
+		t: clock.NewTimer(math.MaxInt64),
+		s: timerStatusPaused,
 	}
 	return &t
 }
 
-func (t *timer) observe(query *BatchQuery) {
-	t.t.Reset(query.config.CollectInterval)
+func (t *timer) observe(query *BatchQuery, s *shard) {
+	t.halt()
+	var (
+		run bool
+		gen uint32
+		// End of collecting period and time of early flush.
+		end time.Time
+		dl  int64
+	)
 	for {
 		select {
-		case signal, ok := <-t.c:
+		case _, ok := <-t.c:
 			if !ok {
 				return
 			}
-			switch signal {
-			case timerSignalReach:
-				atomic.StoreUint32(&t.s, timerStatusPaused)
-				t.t.Stop()
-				query.flush(flushReasonInterval)
-				break
-			case timerSignalPause:
-				atomic.StoreUint32(&t.s, timerStatusPaused)
-				t.t.Stop()
-				break
-			case timerSignalResume:
-				atomic.StoreUint32(&t.s, timerStatusActive)
-				t.t.Reset(query.config.CollectInterval)
-				break
-			case timerSignalStop:
-				atomic.StoreUint32(&t.s, timerStatusStopped)
-				t.t.Stop()
-				close(t.c)
+			switch atomic.LoadUint32(&t.s) {
+			case timerStatusActive:
+				var arm bool
+				now := query.now()
+				if g := atomic.LoadUint32(&t.g); !run || g != gen {
+					end, dl = now.Add(query.collectInterval()), 0
+					run, gen, arm = true, g, true
+				}
+				if d := atomic.LoadInt64(&t.d); d != dl {
+					dl, arm = d, true
+				}
+				if arm {
+					at := end
+					if dl != 0 && dl < at.UnixNano() {
+						at = time.Unix(0, dl)
+					}
+					t.halt()
+					t.t.Reset(at.Sub(now))
+				}
+			case timerStatusPaused:
+				if run {
+					t.halt()
+					run = false
+				}
+			case timerStatusStopped:
+				t.halt()
 				return
 			}
-		case <-t.t.C:
-			t.reach()
+		case <-t.t.C():
+			run = false
+			reason := flushReasonInterval
+			if dl != 0 && dl < end.UnixNano() {
+				reason = flushReasonDeadline
+			}
+			if atomic.CompareAndSwapUint32(&t.s, timerStatusActive, timerStatusPaused) {
+				query.flush(s, reason)
+			}
 		}
 	}
 }
 
-// Send time reach signal.
-func (t *timer) reach() {
-	if atomic.LoadUint32(&t.s) != timerStatusActive {
-		return
+// Stop underlying timer and drain its channel.
+// Underlying timer may fire right before stop, so without draining stale fire would flush the next batch early.
+func (t *timer) halt() {
+	if !t.t.Stop() {
+		select {
+		case <-t.t.C():
+		default:
+		}
 	}
-	t.send(timerSignalReach)
 }
 
 // Send pause signal.
 func (t *timer) pause() {
-	if atomic.LoadUint32(&t.s) != timerStatusActive {
-		return
+	if atomic.CompareAndSwapUint32(&t.s, timerStatusActive, timerStatusPaused) {
+		t.send(timerSignalPause)
 	}
-	t.send(timerSignalPause)
 }
 
 // Check timer is paused.
@@ -94,13 +125,30 @@ func (t *timer) paused() bool {
 // Send resume signal.
 func (t *timer) resume() {
 	if atomic.CompareAndSwapUint32(&t.s, timerStatusPaused, timerStatusActive) {
+		atomic.StoreInt64(&t.d, 0)
+		atomic.AddUint32(&t.g, 1)
 		t.send(timerSignalResume)
 	}
 }
 
+// Schedule early flush at given time, if it's earlier than already scheduled one.
+func (t *timer) deadline(at time.Time) {
+	n := at.UnixNano()
+	for {
+		d := atomic.LoadInt64(&t.d)
+		if d != 0 && d <= n {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&t.d, d, n) {
+			break
+		}
+	}
+	t.send(timerSignalDeadline)
+}
+
 // Send stop signal.
 func (t *timer) stop() {
-	if atomic.LoadUint32(&t.s) == timerStatusStopped {
+	if atomic.SwapUint32(&t.s, timerStatusStopped) == timerStatusStopped {
 		return
 	}
 	t.send(timerSignalStop)
@@ -0,0 +1,158 @@
+package typed
+
+import (
+	"context"
+	"time"
+
+	"github.com/koykov/batch_query"
+)
+
+// BatchQuery is a typed wrapper over batch_query.BatchQuery.
+// Takes keys of type K and returns values of type V, so no type assertions required on the caller side.
+type BatchQuery[K comparable, V any] struct {
+	q *batch_query.BatchQuery
+}
+
+// New makes new typed query instance using given config.
+// Config's batcher may be any untyped batcher (eg. from mods) or typed batcher wrapped using Wrap function.
+func New[K comparable, V any](conf *batch_query.Config) (*BatchQuery[K, V], error) {
+	q, err := batch_query.New(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &BatchQuery[K, V]{q: q}, nil
+}
+
+// Fetch add single request to current batch using default timeout interval.
+func (q *BatchQuery[K, V]) Fetch(key K) (V, error) {
+	return conv[V](q.q.Fetch(key))
+}
+
+// FetchContext add single request to current batch with context.
+func (q *BatchQuery[K, V]) FetchContext(key K, ctx context.Context) (V, error) {
+	return conv[V](q.q.FetchContext(key, ctx))
+}
+
+// FetchTimeout add single request to current batch using given timeout interval.
+func (q *BatchQuery[K, V]) FetchTimeout(key K, timeout time.Duration) (V, error) {
+	return conv[V](q.q.FetchTimeout(key, timeout))
+}
+
+// FetchDeadline add single request to current batch using given deadline.
+func (q *BatchQuery[K, V]) FetchDeadline(key K, deadline time.Time) (V, error) {
+	return conv[V](q.q.FetchDeadline(key, deadline))
+}
+
+// FetchAsync adds single request to current batch and returns future without waiting the response.
+func (q *BatchQuery[K, V]) FetchAsync(key K) *Future[V] {
+	return &Future[V]{f: q.q.FetchAsync(key)}
+}
+
+// FetchMany adds multiple requests to current batch using default timeout interval.
+// Returns values and errors in the same order as keys.
+func (q *BatchQuery[K, V]) FetchMany(keys []K) ([]V, []error) {
+	return q.convMany(q.q.FetchMany(q.ukeys(keys)))
+}
+
+// FetchManyContext adds multiple requests to current batch with context.
+func (q *BatchQuery[K, V]) FetchManyContext(keys []K, ctx context.Context) ([]V, []error) {
+	return q.convMany(q.q.FetchManyContext(q.ukeys(keys), ctx))
+}
+
+// FetchManyTimeout adds multiple requests to current batch using given timeout interval.
+func (q *BatchQuery[K, V]) FetchManyTimeout(keys []K, timeout time.Duration) ([]V, []error) {
+	return q.convMany(q.q.FetchManyTimeout(q.ukeys(keys), timeout))
+}
+
+// FetchManyDeadline adds multiple requests to current batch using given deadline.
+func (q *BatchQuery[K, V]) FetchManyDeadline(keys []K, deadline time.Time) ([]V, []error) {
+	return q.convMany(q.q.FetchManyDeadline(q.ukeys(keys), deadline))
+}
+
+// Invalidate removes given keys from result and negative caches.
+func (q *BatchQuery[K, V]) Invalidate(keys ...K) {
+	q.q.Invalidate(q.ukeys(keys)...)
+}
+
+// Reconfigure applies new config to the active query on the fly.
+// See batch_query.BatchQuery.Reconfigure for details.
+func (q *BatchQuery[K, V]) Reconfigure(conf *batch_query.Config) error {
+	return q.q.Reconfigure(conf)
+}
+
+// Stats returns snapshot of query counters and gauges.
+func (q *BatchQuery[K, V]) Stats() batch_query.Stats {
+	return q.q.Stats()
+}
+
+// Close gracefully stops the query.
+func (q *BatchQuery[K, V]) Close() error {
+	return q.q.Close()
+}
+
+// Shutdown gracefully stops the query and waits until all workers exit.
+// See batch_query.BatchQuery.Shutdown for details.
+func (q *BatchQuery[K, V]) Shutdown(ctx context.Context) error {
+	return q.q.Shutdown(ctx)
+}
+
+// ForceClose closes the query and immediately interrupts all collected and processing requests.
+func (q *BatchQuery[K, V]) ForceClose() error {
+	return q.q.ForceClose()
+}
+
+func (q *BatchQuery[K, V]) Error() error {
+	return q.q.Error()
+}
+
+// Status returns current status of the query.
+func (q *BatchQuery[K, V]) Status() batch_query.Status {
+	return q.q.Status()
+}
+
+// Ready checks if query is active and accepts requests.
+func (q *BatchQuery[K, V]) Ready() bool {
+	return q.q.Ready()
+}
+
+// Wait blocks until query becomes ready or ctx done.
+func (q *BatchQuery[K, V]) Wait(ctx context.Context) error {
+	return q.q.Wait(ctx)
+}
+
+// Healthy checks if query is active and the storage is available.
+func (q *BatchQuery[K, V]) Healthy() error {
+	return q.q.Healthy()
+}
+
+// Untyped returns underlying untyped query.
+func (q *BatchQuery[K, V]) Untyped() *batch_query.BatchQuery {
+	return q.q
+}
+
+func conv[V any](raw any, err error) (v V, _ error) {
+	if err != nil || raw == nil {
+		return v, err
+	}
+	var ok bool
+	if v, ok = raw.(V); !ok {
+		return v, ErrValType
+	}
+	return v, nil
+}
+
+func (q *BatchQuery[K, V]) convMany(raw []any, errs []error) ([]V, []error) {
+	vals := make([]V, len(raw))
+	for i := 0; i < len(raw); i++ {
+		vals[i], errs[i] = conv[V](raw[i], errs[i])
+	}
+	return vals, errs
+}
+
+func (q *BatchQuery[K, V]) ukeys(keys []K) []any {
+	ukeys := make([]any, 0, len(keys))
+	for i := 0; i < len(keys); i++ {
+		ukeys = append(ukeys, keys[i])
+	}
+	return ukeys
+}
@@ -0,0 +1,151 @@
+package typed
+
+import (
+	"context"
+
+	"github.com/koykov/batch_query"
+)
+
+// Batcher describes typed object to process batches.
+// It's a typed counterpart of batch_query.Batcher.
+type Batcher[K comparable, V any] interface {
+	// Batch processes collected batch.
+	Batch(dst []V, keys []K, ctx context.Context) ([]V, error)
+	// MatchKey checks if key corresponds to val.
+	MatchKey(key K, val V) bool
+}
+
+// KeyedBatcher is a typed counterpart of batch_query.KeyedBatcher.
+type KeyedBatcher[K comparable, V any] interface {
+	Batcher[K, V]
+	// ValueIdent returns comparable identity of the key that val corresponds to.
+	// Key itself uses as identity of the key.
+	ValueIdent(val V) (K, bool)
+}
+
+// Wrap converts typed batcher to untyped form to use it in batch_query.Config.
+// Batcher made by Adapt unwraps back to the underlying untyped batcher, thus its optional interfaces keep working.
+func Wrap[K comparable, V any](b Batcher[K, V]) batch_query.Batcher {
+	if a, ok := b.(adapter[K, V]); ok {
+		return a.b
+	}
+	if kb, ok := b.(KeyedBatcher[K, V]); ok {
+		return keyedWrapper[K, V]{wrapper: wrapper[K, V]{b: b}, kb: kb}
+	}
+	return wrapper[K, V]{b: b}
+}
+
+// Adapt converts untyped batcher (eg. any of mods) to typed form.
+// Keys passes to underlying batcher as is, values that doesn't correspond to V fail the batch with ErrValType.
+// Note, that typed form may lack optional interfaces of untyped batcher, so don't use adapted batcher in config
+// directly, but pass untyped batcher to New as is, otherwise the fast paths of mods are lost.
+func Adapt[K comparable, V any](b batch_query.Batcher) Batcher[K, V] {
+	return adapter[K, V]{b: b}
+}
+
+// wrapper represents typed batcher as batch_query.Batcher.
+type wrapper[K comparable, V any] struct {
+	b Batcher[K, V]
+}
+
+func (w wrapper[K, V]) Batch(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	tkeys := make([]K, 0, len(keys))
+	for i := 0; i < len(keys); i++ {
+		k, ok := keys[i].(K)
+		if !ok {
+			return dst, ErrKeyType
+		}
+		tkeys = append(tkeys, k)
+	}
+	vals, err := w.b.Batch(make([]V, 0, len(keys)), tkeys, ctx)
+	if err != nil {
+		return dst, err
+	}
+	for i := 0; i < len(vals); i++ {
+		dst = append(dst, vals[i])
+	}
+	return dst, nil
+}
+
+func (w wrapper[K, V]) MatchKey(key, val any) bool {
+	k, ok := key.(K)
+	if !ok {
+		return false
+	}
+	v, ok := val.(V)
+	if !ok {
+		return false
+	}
+	return w.b.MatchKey(k, v)
+}
+
+// Ping checks the storage if typed batcher implements batch_query.Pinger.
+func (w wrapper[K, V]) Ping(ctx context.Context) error {
+	if p, ok := w.b.(batch_query.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// keyedWrapper represents typed keyed batcher as batch_query.KeyedBatcher.
+type keyedWrapper[K comparable, V any] struct {
+	wrapper[K, V]
+	kb KeyedBatcher[K, V]
+}
+
+func (w keyedWrapper[K, V]) KeyIdent(key any) any {
+	if k, ok := key.(K); ok {
+		return k
+	}
+	return nil
+}
+
+func (w keyedWrapper[K, V]) ValueIdent(val any) any {
+	v, ok := val.(V)
+	if !ok {
+		return nil
+	}
+	if k, ok := w.kb.ValueIdent(v); ok {
+		return k
+	}
+	return nil
+}
+
+// adapter represents batch_query.Batcher as typed batcher.
+type adapter[K comparable, V any] struct {
+	b batch_query.Batcher
+}
+
+func (a adapter[K, V]) Batch(dst []V, keys []K, ctx context.Context) ([]V, error) {
+	ukeys := make([]any, 0, len(keys))
+	for i := 0; i < len(keys); i++ {
+		ukeys = append(ukeys, keys[i])
+	}
+	vals, err := a.b.Batch(make([]any, 0, len(keys)), ukeys, ctx)
+	if err != nil {
+		return dst, err
+	}
+	for i := 0; i < len(vals); i++ {
+		if vals[i] == nil {
+			continue
+		}
+		v, ok := vals[i].(V)
+		if !ok {
+			return dst, ErrValType
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
+
+func (a adapter[K, V]) MatchKey(key K, val V) bool {
+	return a.b.MatchKey(key, val)
+}
+
+// Ping checks the storage if underlying batcher implements batch_query.Pinger.
+func (a adapter[K, V]) Ping(ctx context.Context) error {
+	if p, ok := a.b.(batch_query.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package typed
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/koykov/batch_query"
+)
+
+// Typed batcher, returns string representation of keys.
+type strBatcher struct{}
+
+func (strBatcher) Batch(dst []string, keys []int, _ context.Context) ([]string, error) {
+	for i := 0; i < len(keys); i++ {
+		if keys[i]%10 != 0 {
+			dst = append(dst, strconv.Itoa(keys[i]))
+		}
+	}
+	return dst, nil
+}
+
+func (strBatcher) MatchKey(key int, val string) bool {
+	return strconv.Itoa(key) == val
+}
+
+type keyedStrBatcher struct {
+	strBatcher
+}
+
+func (keyedStrBatcher) ValueIdent(val string) (int, bool) {
+	k, err := strconv.Atoi(val)
+	return k, err == nil
+}
+
+// Untyped batcher, that returns values of different types.
+type anyBatcher struct{}
+
+func (anyBatcher) Batch(dst []any, keys []any, _ context.Context) ([]any, error) {
+	for i := 0; i < len(keys); i++ {
+		if k := keys[i].(int); k < 0 {
+			dst = append(dst, k)
+		} else {
+			dst = append(dst, strconv.Itoa(k))
+		}
+	}
+	return dst, nil
+}
+
+func (anyBatcher) MatchKey(key, val any) bool {
+	return anyBatcher{}.ValueIdent(val) == key
+}
+
+func (anyBatcher) KeyIdent(key any) any {
+	return key
+}
+
+func (anyBatcher) ValueIdent(val any) any {
+	if s, ok := val.(string); ok {
+		k, _ := strconv.Atoi(s)
+		return k
+	}
+	return val
+}
+
+func newQuery[V any](t *testing.T, b batch_query.Batcher) *BatchQuery[int, V] {
+	t.Helper()
+	q, err := New[int, V](&batch_query.Config{BatchSize: 4, CollectInterval: time.Millisecond, TimeoutInterval: time.Second,
+		Workers: 1, Batcher: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.ForceClose() })
+	return q
+}
+
+func TestWrap(t *testing.T) {
+	for _, b := range []Batcher[int, string]{strBatcher{}, keyedStrBatcher{}} {
+		w := Wrap[int, string](b)
+		if _, ok := w.(batch_query.KeyedBatcher); ok != (b == Batcher[int, string](keyedStrBatcher{})) {
+			t.Fatal("keyed interface mismatch")
+		}
+		q := newQuery[string](t, w)
+		vals, errs := q.FetchMany([]int{1, 2, 10})
+		if vals[0] != "1" || vals[1] != "2" || errs[0] != nil || errs[1] != nil {
+			t.Fatalf("unexpected result %v %v", vals, errs)
+		}
+		if errs[2] != batch_query.ErrNotFound {
+			t.Fatalf("unexpected error %v", errs[2])
+		}
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	t.Run("value type", func(t *testing.T) {
+		vals, err := Adapt[int, string](anyBatcher{}).Batch(nil, []int{1, -1}, context.Background())
+		if err != ErrValType {
+			t.Fatalf("unexpected result %v %v", vals, err)
+		}
+	})
+	t.Run("unwrap", func(t *testing.T) {
+		// Adapted batcher keeps optional interfaces of the original one after wrapping.
+		w := Wrap[int, string](Adapt[int, string](anyBatcher{}))
+		if _, ok := w.(batch_query.KeyedBatcher); !ok {
+			t.Fatal("keyed interface lost")
+		}
+	})
+}
+
+func TestFetch(t *testing.T) {
+	q := newQuery[string](t, anyBatcher{})
+	if val, err := q.Fetch(5); err != nil || val != "5" {
+		t.Fatalf("unexpected result %v %v", val, err)
+	}
+	if _, err := q.Fetch(-1); err != ErrValType {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if val, err := q.FetchAsync(7).Wait(); err != nil || val != "7" {
+		t.Fatalf("unexpected result %v %v", val, err)
+	}
+}
@@ -0,0 +1,8 @@
+package typed
+
+import "errors"
+
+var (
+	ErrKeyType = errors.New("key doesn't match the query key type")
+	ErrValType = errors.New("value doesn't match the query value type")
+)
@@ -0,0 +1,32 @@
+package typed
+
+import (
+	"context"
+
+	"github.com/koykov/batch_query"
+)
+
+// Future is a typed wrapper over batch_query.Future.
+type Future[V any] struct {
+	f *batch_query.Future
+}
+
+// Done returns a channel that's closed when response is available.
+func (f *Future[V]) Done() <-chan struct{} {
+	return f.f.Done()
+}
+
+// Wait blocks until response will available or timeout interval of the query reached.
+func (f *Future[V]) Wait() (V, error) {
+	return conv[V](f.f.Wait())
+}
+
+// WaitContext blocks until response will available or context done.
+func (f *Future[V]) WaitContext(ctx context.Context) (V, error) {
+	return conv[V](f.f.WaitContext(ctx))
+}
+
+// Result returns response without blocking.
+func (f *Future[V]) Result() (V, error) {
+	return conv[V](f.f.Result())
+}
@@ -1,11 +1,72 @@
 package batch_query
 
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
 // pair represents internal request in batches.
 // See tuple type.
 type pair struct {
-	key  any
-	c    chan tuple
-	done bool
+	key any
+	// Comparable identity of the key, nil if key has no identity.
+	id any
+	// Deadline of the request, zero value means no deadline.
+	dl time.Time
+	// Response receiver, either future of single request or channel of multi-key request.
+	f *Future
+	c chan tuple
+	// Index of the key in multi-key request.
+	i int
+	// Requesters group of multi-key request.
+	g *group
+}
+
+// Check if requester doesn't wait the response anymore.
+func (p *pair) gone() bool {
+	if p.f != nil {
+		return atomic.LoadUint32(&p.f.s) == futureStatusAbandoned
+	}
+	return p.g != nil && atomic.LoadUint32(&p.g.gone) != 0
+}
+
+// Register the requester in batch waiters.
+// Returns false if all waiters of the batch have already gone.
+func (p *pair) watch(w *waiters) bool {
+	switch {
+	case p.f != nil:
+		return p.f.watch(w)
+	case p.g != nil:
+		return p.g.watch(w)
+	default:
+		return w.alive()
+	}
+}
+
+// Send response to the requester.
+// Requesters that don't wait the response anymore are skipped.
+func (p *pair) reply(val any, err error) {
+	if p.gone() {
+		return
+	}
+	if p.f != nil {
+		p.f.resolve(val, err)
+		return
+	}
+	p.c <- tuple{val: val, err: err, i: p.i}
+}
+
+// Send result of the key to the requester.
+func (p *pair) replyResult(r *Result) {
+	switch {
+	case r.Err != nil:
+		p.reply(nil, r.Err)
+	case r.Value == nil:
+		p.reply(nil, ErrNotFound)
+	default:
+		p.reply(r.Value, nil)
+	}
 }
 
 // tuple represents internal response to single request.
@@ -13,4 +74,24 @@ type pair struct {
 type tuple struct {
 	val any
 	err error
+	i   int
+}
+
+// batch represents collected requests ready to process.
+type batch struct {
+	// Unique keys to process and their identities.
+	keys []any
+	ids  []any
+	// Requests and positions of their keys.
+	pairs []pair
+	pos   []int
+	// The latest deadline of requests, zero value means that at least one request has no deadline.
+	dl time.Time
+	// Reason of the flush.
+	reason flushReason
+	// Flights of unique keys, uses in single flight mode.
+	flights []*flight
+	// Context of the batch and its requesters.
+	ctx context.Context
+	w   *waiters
 }
@@ -0,0 +1,79 @@
+package batch_query
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// waiters counts requesters that wait for the batch and cancels batch context when all of them have gone.
+// Counter starts with one reference of the collector, that releases after registration of batch requests.
+type waiters struct {
+	n      int32
+	cancel context.CancelFunc
+}
+
+func newWaiters(cancel context.CancelFunc) *waiters {
+	w := waiters{n: 1, cancel: cancel}
+	return &w
+}
+
+// Register new requester. Returns false if all requesters have already gone.
+func (w *waiters) join() bool {
+	for {
+		n := atomic.LoadInt32(&w.n)
+		if n <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&w.n, n, n+1) {
+			return true
+		}
+	}
+}
+
+// Unregister requester. The last one cancels the batch context.
+func (w *waiters) leave() {
+	if atomic.AddInt32(&w.n, -1) == 0 {
+		w.cancel()
+	}
+}
+
+// Check if at least one requester still waits.
+func (w *waiters) alive() bool {
+	return atomic.LoadInt32(&w.n) > 0
+}
+
+// group represents requesters of multi-key request.
+type group struct {
+	// Flag that sets when requester stops waiting.
+	gone uint32
+	mux  sync.Mutex
+	ws   []*waiters
+}
+
+// Register the key of the group in batch waiters.
+// Returns false if all waiters of the batch have already gone.
+func (g *group) watch(w *waiters) bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if atomic.LoadUint32(&g.gone) != 0 {
+		return true
+	}
+	if !w.join() {
+		return false
+	}
+	g.ws = append(g.ws, w)
+	return true
+}
+
+// Stop waiting and unregister all keys of the group.
+func (g *group) leave() {
+	atomic.StoreUint32(&g.gone, 1)
+	g.mux.Lock()
+	ws := g.ws
+	g.ws = nil
+	g.mux.Unlock()
+	for i := 0; i < len(ws); i++ {
+		ws[i].leave()
+	}
+}
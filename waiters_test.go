@@ -0,0 +1,66 @@
+package batch_query
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Batcher that exposes contexts of batches.
+type ctxBatcher struct {
+	*testBatcher
+	ctxs chan context.Context
+}
+
+func (b ctxBatcher) Batch(dst []any, keys []any, ctx context.Context) ([]any, error) {
+	b.ctxs <- ctx
+	return b.testBatcher.Batch(dst, keys, ctx)
+}
+
+func TestBatchContext(t *testing.T) {
+	newQuery := func(t *testing.T, delay time.Duration) (*BatchQuery, chan context.Context) {
+		b := ctxBatcher{testBatcher: &testBatcher{delay: delay}, ctxs: make(chan context.Context, 1)}
+		q := newTestQuery(t, &Config{BatchSize: 1, CollectInterval: time.Second, TimeoutInterval: 2 * time.Second,
+			Workers: 1, Batcher: b})
+		return q, b.ctxs
+	}
+	t.Run("deadline", func(t *testing.T) {
+		q, ctxs := newQuery(t, 0)
+		dl := time.Now().Add(time.Second)
+		if _, err := q.FetchDeadline(1, dl); err != nil {
+			t.Fatal(err)
+		}
+		bdl, ok := (<-ctxs).Deadline()
+		if d := bdl.Sub(dl); !ok || d < -100*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("unexpected batch deadline %s, request deadline %s", bdl, dl)
+		}
+	})
+	t.Run("no deadline", func(t *testing.T) {
+		q, ctxs := newQuery(t, 0)
+		if _, err := q.FetchContext(1, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := (<-ctxs).Deadline(); ok {
+			t.Fatal("batch context has deadline")
+		}
+	})
+	t.Run("gone", func(t *testing.T) {
+		// Batch context cancels when the only requester stops waiting.
+		q, ctxs := newQuery(t, 10*time.Second)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			_, err := q.FetchContext(1, ctx)
+			done <- err
+		}()
+		bctx := <-ctxs
+		if bctx.Err() != nil {
+			t.Fatal("batch context cancelled early")
+		}
+		cancel()
+		if err := <-done; err == nil {
+			t.Fatal("cancelled request succeeded")
+		}
+		waitFor(t, func() bool { return bctx.Err() != nil })
+	})
+}
@@ -0,0 +1,314 @@
+package batch_query
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Start new worker.
+func (q *BatchQuery) spawn() {
+	atomic.AddUint32(&q.workers, 1)
+	q.mw().WorkerUp()
+	q.wg.Add(1)
+	go q.worker(q.wctx)
+}
+
+// Start new worker if all workers are busy, buffer is half full or buffer isn't empty and batch latency exceeds
+// collect interval, so workers can't keep up with flushes.
+// Works only in autoscaling mode.
+func (q *BatchQuery) scale() {
+	max := uint32(q.cfg().MaxWorkers)
+	if max == 0 {
+		return
+	}
+	n := atomic.LoadUint32(&q.workers)
+	if n >= max {
+		return
+	}
+	busy := atomic.LoadUint32(&q.busy) >= n
+	full := len(q.c) >= cap(q.c)/2
+	slow := len(q.c) > 0 && time.Duration(atomic.LoadInt64(&q.blast)) > q.collectInterval()
+	if !busy && !full && !slow {
+		return
+	}
+	if atomic.CompareAndSwapUint32(&q.workers, n, n+1) {
+		q.mw().WorkerUp()
+		q.wg.Add(1)
+		go q.worker(q.wctx)
+		if l := q.l(); l != nil {
+			l.Printf("worker started, %d workers running\n", n+1)
+		}
+	}
+}
+
+// Stop idle worker if workers count exceeds the minimum.
+func (q *BatchQuery) shrink() bool {
+	c := q.cfg()
+	if c.MaxWorkers == 0 {
+		return false
+	}
+	min := uint32(c.MinWorkers)
+	for {
+		n := atomic.LoadUint32(&q.workers)
+		if n <= min {
+			return false
+		}
+		if atomic.CompareAndSwapUint32(&q.workers, n, n-1) {
+			q.mw().WorkerDown()
+			if l := q.l(); l != nil {
+				l.Printf("idle worker stopped, %d workers running\n", n-1)
+			}
+			return true
+		}
+	}
+}
+
+// Stop n workers, eg. due to reconfiguration.
+// Counter of pending retirements updates synchronously, workers receive stop signal after processing of current
+// batches.
+func (q *BatchQuery) retire(n uint32) {
+	atomic.AddUint32(&q.retiring, n)
+	go func() {
+		for i := uint32(0); i < n; i++ {
+			select {
+			case q.quit <- struct{}{}:
+			case <-q.wctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Cancel up to n pending retirements. Returns count of cancelled ones.
+func (q *BatchQuery) unretire(n uint32) uint32 {
+	for {
+		r := atomic.LoadUint32(&q.retiring)
+		c := n
+		if c > r {
+			c = r
+		}
+		if c == 0 || atomic.CompareAndSwapUint32(&q.retiring, r, r-c) {
+			return c
+		}
+	}
+}
+
+// Internal worker that processes batches from the buffer.
+// In autoscaling mode worker stops after idle timeout.
+func (q *BatchQuery) worker(ctx context.Context) {
+	defer q.wg.Done()
+	idle := q.clock().NewTimer(q.cfg().WorkerIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case b, ok := <-q.c:
+			if !ok {
+				q.workerDown()
+				return
+			}
+			q.mw().BufferOut()
+			atomic.AddUint32(&q.busy, 1)
+			q.process(b)
+			atomic.AddUint32(&q.busy, ^uint32(0))
+			if !idle.Stop() {
+				select {
+				case <-idle.C():
+				default:
+				}
+			}
+			idle.Reset(q.cfg().WorkerIdleTimeout)
+		case <-q.quit:
+			if q.unretire(1) == 0 {
+				// Retirement has been cancelled.
+				continue
+			}
+			q.workerDown()
+			return
+		case <-idle.C():
+			if q.shrink() {
+				return
+			}
+			idle.Reset(q.cfg().WorkerIdleTimeout)
+		case <-ctx.Done():
+			q.workerDown()
+			return
+		}
+	}
+}
+
+func (q *BatchQuery) workerDown() {
+	atomic.AddUint32(&q.workers, ^uint32(0))
+	q.mw().WorkerDown()
+}
+
+// Exec batch operation and send responses to requesters.
+func (q *BatchQuery) process(b *batch) {
+	idx := atomic.AddUint64(&q.idx, 1)
+	ctx := b.ctx
+	defer b.w.cancel()
+	if bt := q.cfg().BatchTimeout; bt > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = q.withTimeout(ctx, bt)
+		defer cancel()
+	}
+	if !b.w.alive() {
+		// All requesters have gone while batch was waiting in the buffer.
+		if l := q.l(); l != nil {
+			l.Printf("batch #%d of %d keys skipped, no requesters left\n", idx, len(b.keys))
+		}
+		q.land(b, nil, ErrInterrupt)
+		return
+	}
+	if l := q.l(); l != nil {
+		l.Printf("batch #%d of %d keys\n", idx, len(b.keys))
+	}
+	// Exec batch operation.
+	now := q.now()
+	res, err := q.batchRetry(b.keys, b.ids, b.dl, idx, ctx)
+	dur := q.now().Sub(now)
+	atomic.StoreInt64(&q.blast, int64(dur))
+	// Batch cancelled due to all requesters have gone says nothing about the storage.
+	if q.breaker != nil && b.w.alive() {
+		q.breaker.record(q, err != nil, dur)
+	}
+	if q.adaptive != nil && b.w.alive() {
+		q.adaptive.adjust(q, b, dur)
+	}
+	if err != nil && q.wctx.Err() != nil {
+		// Query has been interrupted.
+		err = ErrInterrupt
+	}
+	if err != nil && q.cfg().Bisect && len(b.keys) > 1 && ctx.Err() == nil {
+		if l := q.l(); l != nil {
+			l.Printf("batch #%d failed due to error: %s, start bisecting\n", idx, err.Error())
+		}
+		q.mw().BatchFail()
+		res, err = q.bisect(b.keys, b.ids, b.dl, idx, ctx), nil
+	} else if err != nil {
+		if l := q.l(); l != nil {
+			l.Printf("batch #%d failed due to error: %s\n", idx, err.Error())
+		}
+		q.mw().BatchFail()
+		// Report about error encountered.
+		for i := 0; i < len(b.pairs); i++ {
+			b.pairs[i].reply(nil, err)
+		}
+		q.land(b, nil, err)
+		return
+	} else {
+		q.mw().BatchOK(dur)
+	}
+	q.cacheResults(b, res)
+	// Send results to corresponding requesters.
+	var s, f, r int
+	for i := 0; i < len(b.pairs); i++ {
+		rs := &res[b.pos[i]]
+		switch {
+		case rs.Err != nil:
+			f++
+		case rs.Value == nil:
+			r++
+		default:
+			s++
+		}
+		b.pairs[i].replyResult(rs)
+	}
+	q.land(b, res, nil)
+	if l := q.l(); l != nil {
+		l.Printf("batch #%d finish with %d success jobs, %d jobs failed, %d jobs unresponded\n", idx, s, f, r)
+	}
+}
+
+// Exec batch operation using the most suitable batcher's method.
+// Returns results aligned with keys.
+func (q *BatchQuery) batch(keys, ids []any, ctx context.Context) ([]Result, error) {
+	c := q.cfg()
+	if c.Hedge != nil {
+		return q.hedge(c, keys, ids, ctx)
+	}
+	return batchBy(c.Batcher, keys, ids, ctx)
+}
+
+// Exec batch operation using given batcher.
+// Ids are identities of keys made by the batcher, nil ids mean that identities aren't known yet.
+func batchBy(b Batcher, keys, ids []any, ctx context.Context) (res []Result, err error) {
+	if rb, ok := b.(ResultBatcher); ok {
+		if res, err = rb.BatchResults(make([]Result, 0, len(keys)), keys, ctx); err == nil && len(res) != len(keys) {
+			err = ErrMisaligned
+		}
+		return
+	}
+
+	dst := make([]any, 0, len(keys))
+	ob, ordered := b.(OrderedBatcher)
+	if ordered {
+		if dst, err = ob.BatchOrdered(dst, keys, ctx); err == nil && len(dst) != len(keys) {
+			err = ErrMisaligned
+		}
+	} else {
+		dst, err = b.Batch(dst, keys, ctx)
+	}
+	if err != nil {
+		return
+	}
+	res = make([]Result, len(keys))
+	if ordered {
+		for i := 0; i < len(dst); i++ {
+			res[i].Value = dst[i]
+		}
+	} else if kb, ok := b.(KeyedBatcher); ok {
+		matchKeyed(kb, res, keys, ids, dst)
+	} else {
+		match(b, res, keys, dst)
+	}
+	return
+}
+
+// Match values with keys using MatchKey method. Has O(n*m) complexity.
+func match(b Batcher, res []Result, keys, dst []any) {
+	for i := 0; i < len(dst); i++ {
+		for j := 0; j < len(keys); j++ {
+			if res[j].Value != nil {
+				continue
+			}
+			if b.MatchKey(keys[j], dst[i]) {
+				res[j].Value = dst[i]
+			}
+		}
+	}
+}
+
+// Match values with keys using identities of keys and values. Has O(n+m) complexity.
+// Identities of keys calculate only if ids are omitted.
+func matchKeyed(b KeyedBatcher, res []Result, keys, ids, dst []any) {
+	idx := make(map[any]any, len(dst))
+	for i := 0; i < len(dst); i++ {
+		if id := b.ValueIdent(dst[i]); hashable(id) {
+			if _, ok := idx[id]; !ok {
+				idx[id] = dst[i]
+			}
+		}
+	}
+	for i := 0; i < len(keys); i++ {
+		var id any
+		if ids != nil {
+			id = ids[i]
+		} else {
+			id = b.KeyIdent(keys[i])
+		}
+		if !hashable(id) {
+			// Key without identity matches the usual way.
+			for j := 0; j < len(dst); j++ {
+				if b.MatchKey(keys[i], dst[j]) {
+					res[i].Value = dst[j]
+					break
+				}
+			}
+			continue
+		}
+		if val, ok := idx[id]; ok {
+			res[i].Value = val
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package batch_query
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoscale(t *testing.T) {
+	clk := NewFakeClock(time.Unix(1000, 0))
+	b := &testBatcher{delay: 50 * time.Millisecond}
+	q := newTestQuery(t, &Config{BatchSize: 1, TimeoutInterval: time.Hour, Workers: 1, MinWorkers: 1, MaxWorkers: 4,
+		WorkerIdleTimeout: time.Second, Buffer: 2, Batcher: b, Clock: clk})
+	fs, fc := make([]*Future, 0, 16), make(chan *Future, 16)
+	// Requests block on the full buffer, so fetch them concurrently.
+	go func() {
+		for i := 1; i <= 16; i++ {
+			fc <- q.FetchAsync(i)
+		}
+	}()
+	waitFor(t, func() bool { return q.Stats().Workers == 4 })
+	for i := 0; i < 16; i++ {
+		fs = append(fs, <-fc)
+	}
+	for i := 0; i < len(fs); i++ {
+		if _, err := fs[i].Wait(); err != nil && err != ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+	// Idle workers stop down to the minimum.
+	advanceFor(t, clk, time.Second, func() bool { return atomic.LoadUint32(&q.workers) == 1 })
+	if st := q.Stats(); st.Workers != 1 || st.BusyWorkers != 0 {
+		t.Fatalf("unexpected workers %d/%d", st.BusyWorkers, st.Workers)
+	}
+}